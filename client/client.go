@@ -2,6 +2,7 @@ package client
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
 	"mime"
@@ -9,6 +10,7 @@ import (
 	"net/http"
 	"net/textproto"
 	"reflect"
+	"sync"
 	"time"
 
 	"github.com/go-courier/courier"
@@ -33,6 +35,8 @@ type Client struct {
 	RequestTransformerMgr *httptransport.RequestTransformerMgr
 	HttpTransports        []HttpTransport
 	NewError              func(resp *http.Response) error
+	// dial cleartext HTTP/2 (h2c) with prior knowledge
+	H2C bool
 }
 
 func (c *Client) SetDefaults() {
@@ -100,9 +104,17 @@ func (c *Client) Do(ctx context.Context, req interface{}, metas ...courier.Metad
 		request = request2
 	}
 
+	if tc, ok := httpx.TraceContextFromContext(ctx); ok {
+		tc.InjectTo(request.Header)
+	}
+
 	httpClient := ClientFromContext(ctx)
 	if httpClient == nil {
-		httpClient = GetShortConnClientContext(ctx, c.Timeout, c.HttpTransports...)
+		if c.H2C {
+			httpClient = GetH2CClientContext(ctx, c.Timeout, c.HttpTransports...)
+		} else {
+			httpClient = GetShortConnClientContext(ctx, c.Timeout, c.HttpTransports...)
+		}
 	}
 
 	resp, err := httpClient.Do(request)
@@ -301,3 +313,55 @@ func GetShortConnClientContext(ctx context.Context, timeout time.Duration, httpT
 
 	return client
 }
+
+// GetH2CClientContext returns client which dial cleartext HTTP/2 (h2c) with prior knowledge,
+// connections pooled by transport shared for same default http transport in context.
+func GetH2CClientContext(ctx context.Context, timeout time.Duration, httpTransports ...HttpTransport) *http.Client {
+	return newClientWithTransport(h2cTransportOf(ctx), timeout, httpTransports...)
+}
+
+// h2c transports keyed by default http transport in context, nil key for none
+var h2cTransports sync.Map
+
+func h2cTransportOf(ctx context.Context) *http2.Transport {
+	key := DefaultHttpTransportFromContext(ctx)
+
+	if t, ok := h2cTransports.Load(key); ok {
+		return t.(*http2.Transport)
+	}
+
+	t, _ := h2cTransports.LoadOrStore(key, newH2CTransport(ctx))
+	return t.(*http2.Transport)
+}
+
+func newH2CTransport(ctx context.Context) *http2.Transport {
+	dialContext := (&net.Dialer{
+		Timeout:   5 * time.Second,
+		KeepAlive: 0,
+	}).DialContext
+
+	if t := DefaultHttpTransportFromContext(ctx); t != nil && t.DialContext != nil {
+		dialContext = t.DialContext
+	}
+
+	return &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, cfg *tls.Config) (net.Conn, error) {
+			return dialContext(ctx, network, addr)
+		},
+	}
+}
+
+func newClientWithTransport(t http.RoundTripper, timeout time.Duration, httpTransports ...HttpTransport) *http.Client {
+	client := &http.Client{
+		Timeout:   timeout,
+		Transport: t,
+	}
+
+	for i := range httpTransports {
+		httpTransport := httpTransports[i]
+		client.Transport = httpTransport(client.Transport)
+	}
+
+	return client
+}
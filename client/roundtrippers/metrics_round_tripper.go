@@ -0,0 +1,73 @@
+package roundtrippers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-courier/httptransport/handlers"
+	"github.com/go-courier/metax"
+)
+
+// NewMetricsRoundTripper records RED metrics of requests to registry (handlers.DefaultMetricsRegistry when nil),
+// labeled by operation_id of generated client, method (by handlers.MethodLabel) and status ("error" when request failed):
+//
+//	http_client_requests_total
+//	http_client_request_duration_seconds
+//	http_client_response_size_bytes (when Content-Length known)
+//	http_client_requests_in_flight (labeled by operation_id and method)
+func NewMetricsRoundTripper(registry *handlers.MetricsRegistry) func(roundTripper http.RoundTripper) http.RoundTripper {
+	if registry == nil {
+		registry = handlers.DefaultMetricsRegistry
+	}
+
+	requests := registry.Counter("http_client_requests_total", "Total number of http client requests.", "operation_id", "method", "status")
+	duration := registry.Histogram("http_client_request_duration_seconds", "Duration of http client requests in seconds.", handlers.DefaultDurationBuckets, "operation_id", "method", "status")
+	responseSize := registry.Histogram("http_client_response_size_bytes", "Size of http client response bodies in bytes.", handlers.DefaultSizeBuckets, "operation_id", "method", "status")
+	inFlight := registry.Gauge("http_client_requests_in_flight", "Number of http client requests in flight.", "operation_id", "method")
+
+	return func(roundTripper http.RoundTripper) http.RoundTripper {
+		return &MetricsRoundTripper{
+			nextRoundTripper: roundTripper,
+			requests:         requests,
+			duration:         duration,
+			responseSize:     responseSize,
+			inFlight:         inFlight,
+		}
+	}
+}
+
+type MetricsRoundTripper struct {
+	nextRoundTripper http.RoundTripper
+
+	requests     *handlers.CounterVec
+	duration     *handlers.HistogramVec
+	responseSize *handlers.HistogramVec
+	inFlight     *handlers.GaugeVec
+}
+
+func (rt *MetricsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	startedAt := time.Now()
+
+	// set by generated client
+	operationID := metax.MetaFromContext(req.Context()).Get("operationID")
+	method := handlers.MethodLabel(req.Method)
+
+	rt.inFlight.Inc(operationID, method)
+	defer rt.inFlight.Dec(operationID, method)
+
+	resp, err := rt.nextRoundTripper.RoundTrip(req)
+
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+		if resp.ContentLength >= 0 {
+			rt.responseSize.Observe(float64(resp.ContentLength), operationID, method, status)
+		}
+	}
+
+	rt.requests.Inc(operationID, method, status)
+	rt.duration.Observe(time.Since(startedAt).Seconds(), operationID, method, status)
+
+	return resp, err
+}
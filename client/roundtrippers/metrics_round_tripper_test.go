@@ -0,0 +1,43 @@
+package roundtrippers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-courier/httptransport/handlers"
+	"github.com/go-courier/metax"
+	. "github.com/onsi/gomega"
+)
+
+func TestMetricsRoundTripper(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		_, _ = rw.Write([]byte(`{"id":"1"}`))
+	}))
+	defer srv.Close()
+
+	registry := handlers.NewMetricsRegistry()
+
+	rt := NewMetricsRoundTripper(registry)(http.DefaultTransport)
+
+	ctx := metax.ContextWith(context.Background(), "operationID", "demo.GetByID")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+
+	resp, err := rt.RoundTrip(req)
+	NewWithT(t).Expect(err).To(BeNil())
+	_ = resp.Body.Close()
+
+	req, _ = http.NewRequestWithContext(ctx, http.MethodGet, "http://127.0.0.1:0", nil)
+	_, err = rt.RoundTrip(req)
+	NewWithT(t).Expect(err).NotTo(BeNil())
+
+	buf := bytes.NewBuffer(nil)
+	_, _ = registry.WriteTo(buf)
+
+	NewWithT(t).Expect(buf.String()).To(ContainSubstring(`http_client_requests_total{operation_id="demo.GetByID",method="GET",status="200"} 1` + "\n"))
+	NewWithT(t).Expect(buf.String()).To(ContainSubstring(`http_client_requests_total{operation_id="demo.GetByID",method="GET",status="error"} 1` + "\n"))
+	NewWithT(t).Expect(buf.String()).To(ContainSubstring(`http_client_response_size_bytes_sum{operation_id="demo.GetByID",method="GET",status="200"} 10` + "\n"))
+	NewWithT(t).Expect(buf.String()).To(ContainSubstring(`http_client_requests_in_flight{operation_id="demo.GetByID",method="GET"} 0` + "\n"))
+}
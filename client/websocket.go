@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/go-courier/courier"
+	"github.com/go-courier/httptransport/httpx"
+	"github.com/go-courier/statuserror"
+	"github.com/pkg/errors"
+)
+
+// DialWebSocket sends websocket handshake request of req like Do, returns connection when switched.
+// Errors responded by server returned as errors of NewError.
+func (c *Client) DialWebSocket(ctx context.Context, req interface{}, metas ...courier.Metadata) (*httpx.WebSocketConn, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	request, ok := req.(*http.Request)
+	if !ok {
+		request2, err := c.newRequest(ctx, req, metas...)
+		if err != nil {
+			return nil, statuserror.Wrap(err, http.StatusInternalServerError, "RequestFailed")
+		}
+		request = request2
+	}
+
+	key := httpx.NewWebSocketKey()
+
+	request.Header.Set(httpx.HeaderConnection, "Upgrade")
+	request.Header.Set(httpx.HeaderUpgrade, "websocket")
+	request.Header.Set(httpx.HeaderSecWebSocketVersion, "13")
+	request.Header.Set(httpx.HeaderSecWebSocketKey, key)
+
+	httpClient := ClientFromContext(ctx)
+	if httpClient == nil {
+		httpClient = GetWebSocketClientContext(ctx, c.HttpTransports...)
+	}
+
+	resp, err := httpClient.Do(request)
+	if err != nil {
+		return nil, statuserror.Wrap(err, http.StatusInternalServerError, "RequestFailed")
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		result := &Result{
+			NewError:       c.NewError,
+			TransformerMgr: c.RequestTransformerMgr.TransformerMgr,
+			Response:       resp,
+		}
+		if _, err := result.Into(nil); err != nil {
+			return nil, err
+		}
+		return nil, statuserror.Wrap(errors.Errorf("unexpected status %s of websocket handshake", resp.Status), http.StatusInternalServerError, "BadWebSocketHandshake")
+	}
+
+	rwc, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		resp.Body.Close()
+		return nil, statuserror.Wrap(errors.New("switched body is not writable"), http.StatusInternalServerError, "BadWebSocketHandshake")
+	}
+
+	if resp.Header.Get(httpx.HeaderSecWebSocketAccept) != httpx.WebSocketAcceptKey(key) {
+		rwc.Close()
+		return nil, statuserror.Wrap(errors.New("invalid Sec-WebSocket-Accept"), http.StatusInternalServerError, "BadWebSocketHandshake")
+	}
+
+	conn := httpx.NewWebSocketConn(rwc, nil, false)
+	conn.Subprotocol = resp.Header.Get(httpx.HeaderSecWebSocketProtocol)
+
+	return conn, nil
+}
+
+// GetWebSocketClientContext returns client for websocket handshake,
+// which only uses HTTP/1.1 and has no timeout for the switched connection.
+func GetWebSocketClientContext(ctx context.Context, httpTransports ...HttpTransport) *http.Client {
+	t := DefaultHttpTransportFromContext(ctx)
+
+	if t != nil {
+		t = t.Clone()
+	} else {
+		t = &http.Transport{
+			DialContext: (&net.Dialer{
+				Timeout:   5 * time.Second,
+				KeepAlive: 0,
+			}).DialContext,
+			DisableKeepAlives:     true,
+			TLSHandshakeTimeout:   5 * time.Second,
+			ResponseHeaderTimeout: 5 * time.Second,
+		}
+	}
+
+	// websocket over HTTP/2 not supported
+	t.ForceAttemptHTTP2 = false
+	t.TLSNextProto = map[string]func(authority string, c *tls.Conn) http.RoundTripper{}
+
+	client := &http.Client{
+		Transport: t,
+	}
+
+	for i := range httpTransports {
+		httpTransport := httpTransports[i]
+		client.Transport = httpTransport(client.Transport)
+	}
+
+	return client
+}
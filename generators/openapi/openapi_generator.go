@@ -18,11 +18,11 @@ import (
 	"github.com/pkg/errors"
 )
 
-func NewOpenAPIGenerator(pkg *packagesx.Package) *OpenAPIGenerator {
+func NewOpenAPIGenerator(pkg *packagesx.Package, options ...OperatorScannerOption) *OpenAPIGenerator {
 	return &OpenAPIGenerator{
 		pkg:           pkg,
 		openapi:       oas.NewOpenAPI(),
-		routerScanner: NewRouterScanner(pkg),
+		routerScanner: NewRouterScanner(pkg, options...),
 	}
 }
 
@@ -24,19 +24,43 @@ import (
 	"github.com/pkg/errors"
 )
 
-func NewOperatorScanner(pkg *packagesx.Package) *OperatorScanner {
-	return &OperatorScanner{
+type OperatorScannerOption func(scanner *OperatorScanner)
+
+// WithErrorFormat sets format of error responses same as httptransport.HttpTransport, default httptransport.ErrorFormatStatusErr
+func WithErrorFormat(errorFormat httptransport.ErrorFormat) OperatorScannerOption {
+	return func(scanner *OperatorScanner) {
+		scanner.errorFormat = errorFormat
+	}
+}
+
+// WithContentNegotiation lists all producible media types of responses same as httptransport.HttpTransport with ContentNegotiation
+func WithContentNegotiation(enabled bool) OperatorScannerOption {
+	return func(scanner *OperatorScanner) {
+		scanner.contentNegotiation = enabled
+	}
+}
+
+func NewOperatorScanner(pkg *packagesx.Package, options ...OperatorScannerOption) *OperatorScanner {
+	scanner := &OperatorScanner{
 		pkg:               pkg,
 		DefinitionScanner: NewDefinitionScanner(pkg),
 		StatusErrScanner:  NewStatusErrScanner(pkg),
 	}
+
+	for i := range options {
+		options[i](scanner)
+	}
+
+	return scanner
 }
 
 type OperatorScanner struct {
 	*DefinitionScanner
 	*StatusErrScanner
-	pkg       *packagesx.Package
-	operators map[*types.TypeName]*Operator
+	pkg                *packagesx.Package
+	operators          map[*types.TypeName]*Operator
+	errorFormat        httptransport.ErrorFormat
+	contentNegotiation bool
 }
 
 func (scanner *OperatorScanner) Operator(ctx context.Context, typeName *types.TypeName) *Operator {
@@ -216,12 +240,33 @@ func (scanner *OperatorScanner) scanReturns(ctx context.Context, op *Operator, t
 
 			if scanner.StatusErrScanner.StatusErrType != nil {
 				op.StatusErrors = scanner.StatusErrScanner.StatusErrorsInFunc(method.(*typesutil.TMethod).Func)
-				op.StatusErrorSchema = scanner.DefinitionScanner.GetSchemaByType(ctx, scanner.StatusErrScanner.StatusErrType)
+
+				if scanner.errorFormat != httptransport.ErrorFormatProblem {
+					op.StatusErrorSchema = scanner.DefinitionScanner.GetSchemaByType(ctx, scanner.StatusErrScanner.StatusErrType)
+				}
+
+				if scanner.errorFormat == httptransport.ErrorFormatProblem || scanner.errorFormat == httptransport.ErrorFormatNegotiate {
+					if problemDetailsType := scanner.problemDetailsType(); problemDetailsType != nil {
+						op.ProblemDetailsSchema = scanner.DefinitionScanner.GetSchemaByType(ctx, problemDetailsType)
+					}
+				}
 			}
 		}
 	}
 }
 
+func (scanner *OperatorScanner) problemDetailsType() types.Type {
+	pkg := scanner.pkg.Pkg(pkgImportPathHttpx)
+	if pkg == nil {
+		return nil
+	}
+	typeName := packagesx.NewPackage(pkg).TypeName("ProblemDetails")
+	if typeName == nil {
+		return nil
+	}
+	return typeName.Type()
+}
+
 func (scanner *OperatorScanner) firstValueOfFunc(named *types.Named, name string) (interface{}, bool) {
 	method, ok := typesutil.FromTType(types.NewPointer(named)).MethodByName(name)
 	if ok {
@@ -240,6 +285,21 @@ func (scanner *OperatorScanner) firstValueOfFunc(named *types.Named, name string
 	return nil, false
 }
 
+func (scanner *OperatorScanner) contentTypeOf(tpe types.Type) (string, bool) {
+	if pointer, ok := tpe.(*types.Pointer); ok {
+		tpe = pointer.Elem()
+	}
+
+	if named, ok := tpe.(*types.Named); ok {
+		if v, ok := scanner.firstValueOfFunc(named, "ContentType"); ok {
+			s, _ := v.(string)
+			return s, true
+		}
+	}
+
+	return "", false
+}
+
 func (scanner *OperatorScanner) getResponse(ctx context.Context, tpe types.Type, expr ast.Expr) (statusCode int, response *oas.Response) {
 	response = &oas.Response{}
 
@@ -261,6 +321,10 @@ func (scanner *OperatorScanner) getResponse(ctx context.Context, tpe types.Type,
 						firstCallExpr = false
 						v, _ := scanner.pkg.Eval(callExpr.Args[0])
 						tpe = v.Type
+						// keep content type of wrapped value, like httpx.EventStream, which schema may be replaced by WithSchema
+						if ct, ok := scanner.contentTypeOf(tpe); ok && ct != "" {
+							contentType = ct
+						}
 					}
 					switch e := callExpr.Fun.(type) {
 					case *ast.SelectorExpr:
@@ -302,15 +366,16 @@ func (scanner *OperatorScanner) getResponse(ctx context.Context, tpe types.Type,
 		tpe = pointer.Elem()
 	}
 
-	if named, ok := tpe.(*types.Named); ok {
-		if v, ok := scanner.firstValueOfFunc(named, "ContentType"); ok {
-			if s, ok := v.(string); ok {
-				contentType = s
-			}
-			if contentType == "" {
-				contentType = "*"
-			}
+	if ct, ok := scanner.contentTypeOf(tpe); ok {
+		if ct != "" {
+			contentType = ct
 		}
+		if contentType == "" {
+			contentType = "*"
+		}
+	}
+
+	if named, ok := tpe.(*types.Named); ok {
 		if v, ok := scanner.firstValueOfFunc(named, "StatusCode"); ok {
 			if i, ok := v.(int64); ok {
 				statusCode = int(i)
@@ -318,11 +383,24 @@ func (scanner *OperatorScanner) getResponse(ctx context.Context, tpe types.Type,
 		}
 	}
 
+	contentTypes := []string{contentType}
+
 	if contentType == "" {
-		contentType = httpx.MIME_JSON
+		contentTypes = []string{httpx.MIME_JSON}
+
+		// struct values could be negotiated by Accept
+		if scanner.contentNegotiation {
+			if mimes := transformers.ProducibleMIMEs(typesutil.FromTType(tpe)); len(mimes) > 1 {
+				contentTypes = mimes
+			}
+		}
 	}
 
-	response.AddContent(contentType, oas.NewMediaTypeWithSchema(scanner.DefinitionScanner.GetSchemaByType(ctx, tpe)))
+	schema := scanner.DefinitionScanner.GetSchemaByType(ctx, tpe)
+
+	for _, ct := range contentTypes {
+		response.AddContent(ct, oas.NewMediaTypeWithSchema(schema))
+	}
 
 	return
 }
@@ -383,8 +461,9 @@ type Operator struct {
 	NonBodyParameters map[string]*oas.Parameter
 	RequestBody       *oas.RequestBody
 
-	StatusErrors      []*statuserror.StatusErr
-	StatusErrorSchema *oas.Schema
+	StatusErrors         []*statuserror.StatusErr
+	StatusErrorSchema    *oas.Schema
+	ProblemDetailsSchema *oas.Schema
 
 	SuccessStatus   int
 	SuccessType     types.Type
@@ -439,7 +518,12 @@ func (operator *Operator) BindOperation(method string, operation *oas.Operation,
 
 		resp := oas.NewResponse("")
 		resp.AddExtension(XStatusErrs, statusErrorList)
-		resp.AddContent(httpx.MIME_JSON, oas.NewMediaTypeWithSchema(operator.StatusErrorSchema))
+		if operator.StatusErrorSchema != nil {
+			resp.AddContent(httpx.MIME_JSON, oas.NewMediaTypeWithSchema(operator.StatusErrorSchema))
+		}
+		if operator.ProblemDetailsSchema != nil {
+			resp.AddContent(httpx.MIME_PROBLEM_JSON, oas.NewMediaTypeWithSchema(operator.ProblemDetailsSchema))
+		}
 		operation.AddResponse(statusError.StatusCode(), resp)
 	}
 
@@ -4,12 +4,16 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
 	"testing"
 
 	. "github.com/onsi/gomega"
 
+	"github.com/go-courier/httptransport"
+	"github.com/go-courier/httptransport/httpx"
 	"github.com/go-courier/oas"
 	"github.com/go-courier/packagesx"
 )
@@ -217,6 +221,47 @@ func TestOperatorScanner(t *testing.T) {
       }
     }
   }
+}`,
+		"Events": /* language=json*/ `{
+  "operationId": "Events",
+  "responses": {
+    "200": {
+      "description": "",
+      "content": {
+        "text/event-stream": {
+          "schema": {
+            "$ref": "#/components/schemas/Data"
+          }
+        }
+      }
+    },
+    "499": {
+      "description": "",
+      "content": {
+        "application/json": {
+          "schema": {
+            "$ref": "#/components/schemas/GithubComGoCourierStatuserrorStatusErr"
+          }
+        }
+      },
+      "x-status-errors": [
+        "@StatusErr[ContextCanceled][499000000][ContextCanceled]"
+      ]
+    },
+    "500": {
+      "description": "",
+      "content": {
+        "application/json": {
+          "schema": {
+            "$ref": "#/components/schemas/GithubComGoCourierStatuserrorStatusErr"
+          }
+        }
+      },
+      "x-status-errors": [
+        "@StatusErr[UnknownError][500000000][UnknownError]"
+      ]
+    }
+  }
 }`,
 	}
 
@@ -231,3 +276,68 @@ func TestOperatorScanner(t *testing.T) {
 		})
 	}
 }
+
+func TestOperatorScannerWithErrorFormat(t *testing.T) {
+	cwd, _ := os.Getwd()
+	pkg, _ := packagesx.Load(filepath.Join(cwd, "./testdata/router_scanner/auth"))
+
+	cases := map[httptransport.ErrorFormat][]string{
+		httptransport.ErrorFormatStatusErr: {httpx.MIME_JSON},
+		httptransport.ErrorFormatProblem:   {httpx.MIME_PROBLEM_JSON},
+		httptransport.ErrorFormatNegotiate: {httpx.MIME_JSON, httpx.MIME_PROBLEM_JSON},
+	}
+
+	for errorFormat, contentTypes := range cases {
+		t.Run(string(errorFormat), func(t *testing.T) {
+			scanner := NewOperatorScanner(pkg, WithErrorFormat(errorFormat))
+
+			operation := &oas.Operation{}
+			op := scanner.Operator(context.Background(), pkg.TypeName("RespWithDescribers"))
+			op.BindOperation("", operation, true)
+
+			resp := operation.Responses.Responses[http.StatusInternalServerError]
+
+			keys := make([]string, 0)
+			for contentType := range resp.Content {
+				keys = append(keys, contentType)
+			}
+			sort.Strings(keys)
+
+			NewWithT(t).Expect(keys).To(Equal(contentTypes))
+
+			if mediaType, ok := resp.Content[httpx.MIME_PROBLEM_JSON]; ok {
+				NewWithT(t).Expect(mediaType.Schema.Refer.RefString()).To(Equal("#/components/schemas/GithubComGoCourierHttptransportHttpxProblemDetails"))
+			}
+		})
+	}
+}
+
+func TestOperatorScannerWithContentNegotiation(t *testing.T) {
+	cwd, _ := os.Getwd()
+	pkg, _ := packagesx.Load(filepath.Join(cwd, "../../testdata/server/cmd/app/routes"))
+
+	cases := map[bool][]string{
+		false: {httpx.MIME_JSON},
+		true:  {httpx.MIME_JSON, httpx.MIME_XML},
+	}
+
+	for contentNegotiation, contentTypes := range cases {
+		t.Run(fmt.Sprint(contentNegotiation), func(t *testing.T) {
+			scanner := NewOperatorScanner(pkg, WithContentNegotiation(contentNegotiation))
+
+			operation := &oas.Operation{}
+			op := scanner.Operator(context.Background(), pkg.TypeName("Create"))
+			op.BindOperation(http.MethodPost, operation, true)
+
+			resp := operation.Responses.Responses[http.StatusCreated]
+
+			keys := make([]string, 0)
+			for contentType := range resp.Content {
+				keys = append(keys, contentType)
+			}
+			sort.Strings(keys)
+
+			NewWithT(t).Expect(keys).To(Equal(contentTypes))
+		})
+	}
+}
@@ -14,11 +14,11 @@ import (
 	"github.com/julienschmidt/httprouter"
 )
 
-func NewRouterScanner(pkg *packagesx.Package) *RouterScanner {
+func NewRouterScanner(pkg *packagesx.Package, options ...OperatorScannerOption) *RouterScanner {
 	routerScanner := &RouterScanner{
 		pkg:             pkg,
 		routers:         map[*types.Var]*Router{},
-		operatorScanner: NewOperatorScanner(pkg),
+		operatorScanner: NewOperatorScanner(pkg, options...),
 	}
 
 	routerScanner.init()
@@ -114,3 +114,11 @@ func (RespWithDescribers) Output(ctx context.Context) (r interface{}, err error)
 	)(nil)
 	return resp, nil
 }
+
+type Events struct {
+}
+
+func (Events) Output(ctx context.Context) (interface{}, error) {
+	events := make(chan *httpx.ServerSentEvent)
+	return httpx.WithSchema(Data{})(httpx.NewEventStream(events)), nil
+}
@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/go-courier/httptransport/httpx"
+)
+
+type Compressor struct {
+	// content coding, like gzip, deflate or br
+	Encoding  string
+	NewWriter func(w io.Writer) io.WriteCloser
+}
+
+var GzipCompressor = Compressor{
+	Encoding: "gzip",
+	NewWriter: func(w io.Writer) io.WriteCloser {
+		return gzip.NewWriter(w)
+	},
+}
+
+// DeflateCompressor of zlib format, which is the deflate coding of HTTP
+// https://www.rfc-editor.org/rfc/rfc9110#section-8.4.1.2
+var DeflateCompressor = Compressor{
+	Encoding: "deflate",
+	NewWriter: func(w io.Writer) io.WriteCloser {
+		return zlib.NewWriter(w)
+	},
+}
+
+// content types already compressed, ends with / means prefix
+var DefaultSkippedContentTypes = []string{
+	"image/",
+	"video/",
+	"audio/",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/x-7z-compressed",
+	"application/x-rar-compressed",
+	"application/octet-stream",
+}
+
+type CompressOption struct {
+	// min size of body to compress, default 1024
+	MinSize int
+	// in preference order when same q-value, default gzip and deflate.
+	// brotli could be plugged as Compressor{Encoding: "br"}
+	Compressors []Compressor
+	// default DefaultSkippedContentTypes
+	SkippedContentTypes []string
+}
+
+func (opt *CompressOption) SetDefaults() {
+	if opt.MinSize == 0 {
+		opt.MinSize = 1024
+	}
+	if opt.Compressors == nil {
+		opt.Compressors = []Compressor{GzipCompressor, DeflateCompressor}
+	}
+	if opt.SkippedContentTypes == nil {
+		opt.SkippedContentTypes = DefaultSkippedContentTypes
+	}
+}
+
+// CompressHandler compresses response negotiated from Accept-Encoding.
+// operators could opt out by httpx.WithoutCompression, and handlers by httpx.DisableCompression
+func CompressHandler(opt CompressOption) func(handler http.Handler) http.Handler {
+	opt.SetDefaults()
+
+	return func(handler http.Handler) http.Handler {
+		return &compressHandler{
+			CompressOption: opt,
+			nextHandler:    handler,
+		}
+	}
+}
+
+type compressHandler struct {
+	CompressOption
+	nextHandler http.Handler
+}
+
+func (h *compressHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	httpx.AddVary(rw.Header(), httpx.HeaderAcceptEncoding)
+
+	compressor := h.negotiate(req.Header.Get(httpx.HeaderAcceptEncoding))
+	if compressor == nil || req.Method == http.MethodHead {
+		h.nextHandler.ServeHTTP(rw, req)
+		return
+	}
+
+	crw := &CompressResponseWriter{
+		rw:         rw,
+		compressor: compressor,
+		opt:        &h.CompressOption,
+	}
+	defer crw.Close()
+
+	h.nextHandler.ServeHTTP(crw, req.WithContext(httpx.ContextWithCompressionDisabler(req.Context(), crw.disable)))
+}
+
+func (h *compressHandler) negotiate(acceptEncoding string) *Compressor {
+	if acceptEncoding == "" {
+		return nil
+	}
+
+	encodings := make([]string, len(h.Compressors))
+	for i := range h.Compressors {
+		encodings[i] = h.Compressors[i].Encoding
+	}
+
+	encoding, ok := httpx.Negotiate(httpx.ParseAccept(acceptEncoding), encodings, func(value string, encoding string) int {
+		switch value {
+		case encoding:
+			return 1
+		case "*":
+			return 0
+		}
+		return -1
+	})
+	if !ok {
+		return nil
+	}
+
+	for i := range h.Compressors {
+		if h.Compressors[i].Encoding == encoding {
+			return &h.Compressors[i]
+		}
+	}
+
+	return nil
+}
+
+type CompressResponseWriter struct {
+	rw         http.ResponseWriter
+	compressor *Compressor
+	opt        *CompressOption
+
+	statusCode    int
+	headerWritten bool
+	// decided to compress or not
+	decided bool
+	// opted out by httpx.DisableCompression
+	disabled bool
+	buf      []byte
+	w        io.WriteCloser
+}
+
+func (rw *CompressResponseWriter) Header() http.Header {
+	return rw.rw.Header()
+}
+
+func (rw *CompressResponseWriter) WriteHeader(statusCode int) {
+	if rw.statusCode != 0 {
+		return
+	}
+
+	rw.statusCode = statusCode
+
+	if !bodyAllowed(statusCode) {
+		rw.decide(false)
+	}
+}
+
+func (rw *CompressResponseWriter) Write(data []byte) (int, error) {
+	if rw.statusCode == 0 {
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	if rw.decided {
+		if rw.w != nil {
+			return rw.w.Write(data)
+		}
+		return rw.rw.Write(data)
+	}
+
+	rw.buf = append(rw.buf, data...)
+
+	if len(rw.buf) >= rw.opt.MinSize {
+		if err := rw.flushBuf(rw.compressible()); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(data), nil
+}
+
+func (rw *CompressResponseWriter) Flush() {
+	if !rw.decided {
+		if rw.statusCode == 0 {
+			rw.WriteHeader(http.StatusOK)
+		}
+		// streaming, ignore min size
+		_ = rw.flushBuf(len(rw.buf) > 0 && rw.compressible())
+	}
+
+	if f, ok := rw.w.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+
+	// through wrappers with Unwrap only, like LoggerResponseWriter
+	_ = http.NewResponseController(rw.rw).Flush()
+}
+
+func (rw *CompressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(rw.rw).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	rw.decided = true
+	return conn, brw, nil
+}
+
+// Unwrap for http.ResponseController
+func (rw *CompressResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.rw
+}
+
+func (rw *CompressResponseWriter) WriteError(err error) {
+	if rwe, ok := rw.rw.(interface{ WriteError(err error) }); ok {
+		rwe.WriteError(err)
+	}
+}
+
+// Close flushes buffered data and closes compressor
+func (rw *CompressResponseWriter) Close() error {
+	if !rw.decided {
+		if rw.statusCode == 0 {
+			// nothing written
+			return nil
+		}
+		if err := rw.flushBuf(false); err != nil {
+			return err
+		}
+	}
+
+	if rw.w != nil {
+		return rw.w.Close()
+	}
+
+	return nil
+}
+
+func (rw *CompressResponseWriter) flushBuf(compress bool) error {
+	rw.decide(compress)
+
+	buf := rw.buf
+	rw.buf = nil
+
+	if len(buf) == 0 {
+		return nil
+	}
+
+	if rw.w != nil {
+		_, err := rw.w.Write(buf)
+		return err
+	}
+
+	_, err := rw.rw.Write(buf)
+	return err
+}
+
+func (rw *CompressResponseWriter) decide(compress bool) {
+	if rw.decided {
+		return
+	}
+	rw.decided = true
+
+	header := rw.rw.Header()
+
+	if compress && !rw.disabled {
+		header.Set(httpx.HeaderContentEncoding, rw.compressor.Encoding)
+		header.Del(httpx.HeaderContentLength)
+		// compressed bytes differ from identity ones, so strong ETag not shared with them
+		if etag := header.Get(httpx.HeaderETag); etag != "" && !strings.HasPrefix(etag, "W/") {
+			header.Set(httpx.HeaderETag, "W/"+etag)
+		}
+		rw.w = rw.compressor.NewWriter(rw.rw)
+	}
+
+	rw.rw.WriteHeader(rw.statusCode)
+}
+
+func (rw *CompressResponseWriter) disable() {
+	rw.disabled = true
+}
+
+func (rw *CompressResponseWriter) compressible() bool {
+	header := rw.rw.Header()
+
+	if header.Get(httpx.HeaderContentEncoding) != "" {
+		return false
+	}
+
+	// ranges are of uncompressed body
+	if rw.statusCode == http.StatusPartialContent || header.Get(httpx.HeaderContentRange) != "" {
+		return false
+	}
+
+	contentType := header.Get(httpx.HeaderContentType)
+	if contentType == "" {
+		contentType = http.DetectContentType(rw.buf)
+		header.Set(httpx.HeaderContentType, contentType)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	for _, skipped := range rw.opt.SkippedContentTypes {
+		if strings.HasSuffix(skipped, "/") {
+			if strings.HasPrefix(mediaType, skipped) {
+				return false
+			}
+			continue
+		}
+		if mediaType == skipped {
+			return false
+		}
+	}
+
+	return true
+}
+
+func bodyAllowed(statusCode int) bool {
+	if statusCode >= 100 && statusCode <= 199 {
+		return false
+	}
+	return statusCode != http.StatusNoContent && statusCode != http.StatusNotModified
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-courier/httptransport/httpx"
+	"github.com/go-courier/httptransport/testify"
+	. "github.com/onsi/gomega"
+)
+
+func TestCompressHandler(t *testing.T) {
+	largeJSON := `{"data":"` + strings.Repeat("a", 2048) + `"}`
+
+	serve := func(acceptEncoding string, handle http.HandlerFunc) *testify.MockResponseWriter {
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		if acceptEncoding != "" {
+			req.Header.Set(httpx.HeaderAcceptEncoding, acceptEncoding)
+		}
+		rw := testify.NewMockResponseWriter()
+		CompressHandler(CompressOption{})(handle).ServeHTTP(rw, req)
+		return rw
+	}
+
+	writeJSON := func(body string) http.HandlerFunc {
+		return func(rw http.ResponseWriter, req *http.Request) {
+			httpx.MaybeWriteHeader(req.Context(), rw, httpx.MIME_JSON, nil)
+			_, _ = io.WriteString(rw, body)
+		}
+	}
+
+	t.Run("gzip", func(t *testing.T) {
+		rw := serve("gzip, deflate", writeJSON(largeJSON))
+
+		NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusOK))
+		NewWithT(t).Expect(rw.Header().Get("Content-Encoding")).To(Equal("gzip"))
+		NewWithT(t).Expect(rw.Header().Get("Content-Type")).To(Equal(httpx.MIME_JSON))
+		NewWithT(t).Expect(rw.Header().Get("Vary")).To(Equal("Accept-Encoding"))
+
+		r, err := gzip.NewReader(bytes.NewReader(rw.Bytes()))
+		NewWithT(t).Expect(err).To(BeNil())
+		data, _ := io.ReadAll(r)
+		NewWithT(t).Expect(string(data)).To(Equal(largeJSON))
+	})
+
+	t.Run("deflate by q-value", func(t *testing.T) {
+		rw := serve("gzip;q=0.5, deflate", writeJSON(largeJSON))
+
+		NewWithT(t).Expect(rw.Header().Get("Content-Encoding")).To(Equal("deflate"))
+
+		r, err := zlib.NewReader(bytes.NewReader(rw.Bytes()))
+		NewWithT(t).Expect(err).To(BeNil())
+		data, _ := io.ReadAll(r)
+		NewWithT(t).Expect(string(data)).To(Equal(largeJSON))
+	})
+
+	t.Run("weak etag when compressed", func(t *testing.T) {
+		withETag := func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set(httpx.HeaderETag, `"v1"`)
+			httpx.AddVary(rw.Header(), httpx.HeaderAcceptEncoding)
+			writeJSON(largeJSON)(rw, req)
+		}
+
+		rw := serve("gzip", withETag)
+		NewWithT(t).Expect(rw.Header().Get("Content-Encoding")).To(Equal("gzip"))
+		NewWithT(t).Expect(rw.Header().Get("ETag")).To(Equal(`W/"v1"`))
+		NewWithT(t).Expect(rw.Header().Values("Vary")).To(Equal([]string{"Accept-Encoding"}))
+
+		rw = serve("", withETag)
+		NewWithT(t).Expect(rw.Header().Get("ETag")).To(Equal(`"v1"`))
+	})
+
+	t.Run("small body", func(t *testing.T) {
+		rw := serve("gzip", writeJSON(`{}`))
+
+		NewWithT(t).Expect(rw.Header().Get("Content-Encoding")).To(BeEmpty())
+		NewWithT(t).Expect(rw.String()).To(Equal(`{}`))
+	})
+
+	t.Run("not accepted", func(t *testing.T) {
+		rw := serve("br, gzip;q=0", writeJSON(largeJSON))
+
+		NewWithT(t).Expect(rw.Header().Get("Content-Encoding")).To(BeEmpty())
+		NewWithT(t).Expect(rw.String()).To(Equal(largeJSON))
+	})
+
+	t.Run("not accepted by more specific", func(t *testing.T) {
+		rw := serve("*, gzip;q=0", writeJSON(largeJSON))
+		NewWithT(t).Expect(rw.Header().Get("Content-Encoding")).To(Equal("deflate"))
+
+		rw = serve("*, gzip;q=0, deflate;q=0", writeJSON(largeJSON))
+		NewWithT(t).Expect(rw.Header().Get("Content-Encoding")).To(BeEmpty())
+		NewWithT(t).Expect(rw.String()).To(Equal(largeJSON))
+	})
+
+	t.Run("compressed content type", func(t *testing.T) {
+		rw := serve("gzip", func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set(httpx.HeaderContentType, "application/zip")
+			_, _ = io.WriteString(rw, largeJSON)
+		})
+
+		NewWithT(t).Expect(rw.Header().Get("Content-Encoding")).To(BeEmpty())
+		NewWithT(t).Expect(rw.String()).To(Equal(largeJSON))
+	})
+
+	t.Run("opt out", func(t *testing.T) {
+		rw := serve("gzip", func(rw http.ResponseWriter, req *http.Request) {
+			httpx.DisableCompression(req.Context())
+			httpx.MaybeWriteHeader(req.Context(), rw, httpx.MIME_JSON, nil)
+			_, _ = io.WriteString(rw, largeJSON)
+		})
+
+		NewWithT(t).Expect(rw.Header().Get("Content-Encoding")).To(BeEmpty())
+		NewWithT(t).Expect(rw.String()).To(Equal(largeJSON))
+	})
+
+	t.Run("no content", func(t *testing.T) {
+		rw := serve("gzip", func(rw http.ResponseWriter, req *http.Request) {
+			rw.WriteHeader(http.StatusNoContent)
+		})
+
+		NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusNoContent))
+		NewWithT(t).Expect(rw.Header().Get("Content-Encoding")).To(BeEmpty())
+	})
+}
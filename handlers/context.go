@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/go-courier/httptransport/httpx"
+	"github.com/go-courier/statuserror"
+	contextx "github.com/go-courier/x/context"
+)
+
+type contextKeyOperationID struct{}
+
+// ContextWithOperationID sets operation id of matched route,
+// called by HttpTransport before all middlewares
+func ContextWithOperationID(ctx context.Context, operationID string) context.Context {
+	return contextx.WithValue(ctx, contextKeyOperationID{}, operationID)
+}
+
+// OperationIDFromContext returns operation id of matched route, empty for unmatched routes
+func OperationIDFromContext(ctx context.Context) string {
+	operationID, _ := ctx.Value(contextKeyOperationID{}).(string)
+	return operationID
+}
+
+type contextKeyAllowedMethods struct{}
+
+// ContextWithAllowedMethods sets resolver of allowed methods of request path for middlewares,
+// called by HttpTransport with HttpTransport.AllowedMethods
+func ContextWithAllowedMethods(ctx context.Context, allowedMethods func(path string) []string) context.Context {
+	return contextx.WithValue(ctx, contextKeyAllowedMethods{}, allowedMethods)
+}
+
+// AllowedMethodsFromContext returns resolver of allowed methods of request path, nil when not set
+func AllowedMethodsFromContext(ctx context.Context) func(path string) []string {
+	allowedMethods, _ := ctx.Value(contextKeyAllowedMethods{}).(func(path string) []string)
+	return allowedMethods
+}
+
+// ErrorWriter writes error as response
+type ErrorWriter func(rw http.ResponseWriter, req *http.Request, err error)
+
+type contextKeyErrorWriter struct{}
+
+// ContextWithErrorWriter sets writer of error responses for middlewares,
+// called by HttpTransport for same error responses of routes, with source of service and ErrorFormat
+func ContextWithErrorWriter(ctx context.Context, writeError ErrorWriter) context.Context {
+	return contextx.WithValue(ctx, contextKeyErrorWriter{}, writeError)
+}
+
+// ErrorWriterFromContext returns writer of error responses, writes json of StatusErr when not set
+func ErrorWriterFromContext(ctx context.Context) ErrorWriter {
+	if writeError, ok := ctx.Value(contextKeyErrorWriter{}).(ErrorWriter); ok {
+		return writeError
+	}
+	return writeStatusErrJSON
+}
+
+func writeStatusErrJSON(rw http.ResponseWriter, req *http.Request, err error) {
+	statusErr := statuserror.FromErr(err)
+
+	if rwe, ok := rw.(interface{ WriteError(err error) }); ok {
+		rwe.WriteError(statusErr)
+	}
+
+	rw.Header().Set(httpx.HeaderContentType, httpx.MIME_JSON+"; charset=utf-8")
+	rw.WriteHeader(statusErr.StatusCode())
+	_ = json.NewEncoder(rw).Encode(statusErr)
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-courier/httptransport/httpx"
+	"github.com/pkg/errors"
+)
+
+// exposed by default, set by HttpRouteHandler and TraceContextHandler
+var defaultExposedHeaders = []string{"X-Meta", httpx.HeaderRequestID}
+
+type CORSOption struct {
+	// resolve allowed methods of request path, AllowedMethodsFromContext when nil, set by HttpTransport.
+	// preflight requests of paths without allowed methods will pass to next handler.
+	AllowedMethods func(path string) []string
+	// matched by origin in order
+	Policies []CORSPolicy
+}
+
+type CORSPolicy struct {
+	// exact origins, "*" for any or "https://*.example.com" for sub domains
+	AllowedOrigins []string
+	// allowed request headers, request headers of preflight will be reflected when empty
+	AllowedHeaders []string
+	// exposed response headers, X-Meta and X-Request-ID always exposed
+	ExposedHeaders []string
+	// not allowed with "*" of AllowedOrigins, which lets any site make credentialed requests
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+func (p *CORSPolicy) Match(origin string) bool {
+	for _, allowed := range p.AllowedOrigins {
+		if allowed == "*" || allowed == origin {
+			return true
+		}
+		if i := strings.Index(allowed, "*"); i >= 0 {
+			prefix, suffix := allowed[:i], allowed[i+1:]
+			if len(origin) >= len(prefix)+len(suffix) && strings.HasPrefix(origin, prefix) && strings.HasSuffix(origin, suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// CORSHandler handles CORS by policies, panics when "*" of AllowedOrigins combined with AllowCredentials
+func CORSHandler(opt CORSOption) func(handler http.Handler) http.Handler {
+	for _, policy := range opt.Policies {
+		if !policy.AllowCredentials {
+			continue
+		}
+		for _, allowed := range policy.AllowedOrigins {
+			if allowed == "*" {
+				panic(errors.Errorf("AllowCredentials of CORSPolicy should not be used with any origin *, list allowed origins instead"))
+			}
+		}
+	}
+
+	return func(handler http.Handler) http.Handler {
+		return &corsHandler{
+			CORSOption:  opt,
+			nextHandler: handler,
+		}
+	}
+}
+
+type corsHandler struct {
+	CORSOption
+	nextHandler http.Handler
+}
+
+func (h *corsHandler) policy(origin string) *CORSPolicy {
+	for i := range h.Policies {
+		if h.Policies[i].Match(origin) {
+			return &h.Policies[i]
+		}
+	}
+	return nil
+}
+
+func (h *corsHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	origin := req.Header.Get(httpx.HeaderOrigin)
+	if origin == "" {
+		h.nextHandler.ServeHTTP(rw, req)
+		return
+	}
+
+	header := rw.Header()
+	httpx.AddVary(header, httpx.HeaderOrigin)
+
+	policy := h.policy(origin)
+	if policy == nil {
+		h.nextHandler.ServeHTTP(rw, req)
+		return
+	}
+
+	allowOrigin := origin
+	if !policy.AllowCredentials && len(policy.AllowedOrigins) == 1 && policy.AllowedOrigins[0] == "*" {
+		allowOrigin = "*"
+	}
+
+	if req.Method == http.MethodOptions && req.Header.Get(httpx.HeaderAccessControlRequestMethod) != "" {
+		methods := h.allowedMethods(req)
+		if len(methods) == 0 {
+			h.nextHandler.ServeHTTP(rw, req)
+			return
+		}
+
+		httpx.AddVary(header, httpx.HeaderAccessControlRequestMethod)
+		httpx.AddVary(header, httpx.HeaderAccessControlRequestHeaders)
+
+		header.Set(httpx.HeaderAccessControlAllowOrigin, allowOrigin)
+		header.Set(httpx.HeaderAccessControlAllowMethods, strings.Join(methods, ", "))
+
+		if len(policy.AllowedHeaders) > 0 {
+			header.Set(httpx.HeaderAccessControlAllowHeaders, strings.Join(policy.AllowedHeaders, ", "))
+		} else if requestHeaders := req.Header.Get(httpx.HeaderAccessControlRequestHeaders); requestHeaders != "" {
+			header.Set(httpx.HeaderAccessControlAllowHeaders, requestHeaders)
+		}
+
+		if policy.AllowCredentials {
+			header.Set(httpx.HeaderAccessControlAllowCredentials, "true")
+		}
+
+		if policy.MaxAge > 0 {
+			header.Set(httpx.HeaderAccessControlMaxAge, strconv.Itoa(int(policy.MaxAge/time.Second)))
+		}
+
+		rw.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	header.Set(httpx.HeaderAccessControlAllowOrigin, allowOrigin)
+
+	if policy.AllowCredentials {
+		header.Set(httpx.HeaderAccessControlAllowCredentials, "true")
+	}
+
+	header.Set(httpx.HeaderAccessControlExposeHeaders, strings.Join(append(append([]string{}, defaultExposedHeaders...), policy.ExposedHeaders...), ", "))
+
+	h.nextHandler.ServeHTTP(rw, req)
+}
+
+func (h *corsHandler) allowedMethods(req *http.Request) []string {
+	if h.AllowedMethods != nil {
+		return h.AllowedMethods(req.URL.Path)
+	}
+	if allowedMethods := AllowedMethodsFromContext(req.Context()); allowedMethods != nil {
+		return allowedMethods(req.URL.Path)
+	}
+	return nil
+}
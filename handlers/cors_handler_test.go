@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-courier/httptransport/testify"
+	. "github.com/onsi/gomega"
+)
+
+func TestCORSHandler(t *testing.T) {
+	reached := false
+
+	var handle http.HandlerFunc = func(rw http.ResponseWriter, req *http.Request) {
+		reached = true
+		rw.WriteHeader(http.StatusOK)
+	}
+
+	handler := CORSHandler(CORSOption{
+		AllowedMethods: func(path string) []string {
+			if path == "/users" {
+				return []string{http.MethodGet, http.MethodPost}
+			}
+			return nil
+		},
+		Policies: []CORSPolicy{
+			{
+				AllowedOrigins:   []string{"https://*.example.com"},
+				AllowCredentials: true,
+				ExposedHeaders:   []string{"X-Total"},
+				MaxAge:           time.Hour,
+			},
+			{
+				AllowedOrigins: []string{"*"},
+			},
+		},
+	})(handle)
+
+	serve := func(method string, path string, header http.Header) *testify.MockResponseWriter {
+		reached = false
+		req, _ := http.NewRequest(method, path, nil)
+		for k, v := range header {
+			req.Header[k] = v
+		}
+		rw := testify.NewMockResponseWriter()
+		handler.ServeHTTP(rw, req)
+		return rw
+	}
+
+	t.Run("preflight", func(t *testing.T) {
+		rw := serve(http.MethodOptions, "/users", http.Header{
+			"Origin":                         {"https://app.example.com"},
+			"Access-Control-Request-Method":  {"POST"},
+			"Access-Control-Request-Headers": {"Authorization"},
+		})
+
+		NewWithT(t).Expect(reached).To(BeFalse())
+		NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusNoContent))
+		NewWithT(t).Expect(rw.Header().Get("Access-Control-Allow-Origin")).To(Equal("https://app.example.com"))
+		NewWithT(t).Expect(rw.Header().Get("Access-Control-Allow-Methods")).To(Equal("GET, POST"))
+		NewWithT(t).Expect(rw.Header().Get("Access-Control-Allow-Headers")).To(Equal("Authorization"))
+		NewWithT(t).Expect(rw.Header().Get("Access-Control-Allow-Credentials")).To(Equal("true"))
+		NewWithT(t).Expect(rw.Header().Get("Access-Control-Max-Age")).To(Equal("3600"))
+		NewWithT(t).Expect(rw.Header().Values("Vary")).To(Equal([]string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}))
+	})
+
+	t.Run("preflight of unknown path", func(t *testing.T) {
+		serve(http.MethodOptions, "/unknown", http.Header{
+			"Origin":                        {"https://app.example.com"},
+			"Access-Control-Request-Method": {"POST"},
+		})
+		NewWithT(t).Expect(reached).To(BeTrue())
+	})
+
+	t.Run("request with Vary", func(t *testing.T) {
+		reached = false
+		req, _ := http.NewRequest(http.MethodGet, "/users", nil)
+		req.Header.Set("Origin", "https://other.com")
+		rw := testify.NewMockResponseWriter()
+		rw.Header().Set("Vary", "Accept-Encoding, Origin")
+		handler.ServeHTTP(rw, req)
+
+		NewWithT(t).Expect(reached).To(BeTrue())
+		NewWithT(t).Expect(rw.Header().Values("Vary")).To(Equal([]string{"Accept-Encoding, Origin"}))
+	})
+
+	t.Run("request with any origin", func(t *testing.T) {
+		rw := serve(http.MethodGet, "/users", http.Header{
+			"Origin": {"https://other.com"},
+		})
+
+		NewWithT(t).Expect(reached).To(BeTrue())
+		NewWithT(t).Expect(rw.Header().Get("Access-Control-Allow-Origin")).To(Equal("*"))
+		NewWithT(t).Expect(rw.Header().Get("Access-Control-Allow-Credentials")).To(BeEmpty())
+		NewWithT(t).Expect(rw.Header().Get("Access-Control-Expose-Headers")).To(Equal("X-Meta, X-Request-ID"))
+	})
+
+	t.Run("request with credentials", func(t *testing.T) {
+		rw := serve(http.MethodGet, "/users", http.Header{
+			"Origin": {"https://app.example.com"},
+		})
+
+		NewWithT(t).Expect(rw.Header().Get("Access-Control-Allow-Origin")).To(Equal("https://app.example.com"))
+		NewWithT(t).Expect(rw.Header().Get("Access-Control-Expose-Headers")).To(Equal("X-Meta, X-Request-ID, X-Total"))
+	})
+
+	t.Run("credentials with any origin", func(t *testing.T) {
+		NewWithT(t).Expect(func() {
+			CORSHandler(CORSOption{
+				Policies: []CORSPolicy{
+					{
+						AllowedOrigins:   []string{"*"},
+						AllowCredentials: true,
+					},
+				},
+			})
+		}).To(Panic())
+	})
+
+	t.Run("request without origin", func(t *testing.T) {
+		rw := serve(http.MethodGet, "/users", nil)
+
+		NewWithT(t).Expect(reached).To(BeTrue())
+		NewWithT(t).Expect(rw.Header().Get("Access-Control-Allow-Origin")).To(BeEmpty())
+	})
+
+	t.Run("preflight by allowed methods from context", func(t *testing.T) {
+		handler := CORSHandler(CORSOption{
+			Policies: []CORSPolicy{
+				{
+					AllowedOrigins: []string{"*"},
+				},
+			},
+		})(handle)
+
+		serve := func(path string, ctx context.Context) *testify.MockResponseWriter {
+			reached = false
+			req, _ := http.NewRequestWithContext(ctx, http.MethodOptions, path, nil)
+			req.Header.Set("Origin", "https://other.com")
+			req.Header.Set("Access-Control-Request-Method", "POST")
+			rw := testify.NewMockResponseWriter()
+			handler.ServeHTTP(rw, req)
+			return rw
+		}
+
+		ctx := ContextWithAllowedMethods(context.Background(), func(path string) []string {
+			if path == "/users" {
+				return []string{http.MethodPost}
+			}
+			return nil
+		})
+
+		rw := serve("/users", ctx)
+		NewWithT(t).Expect(reached).To(BeFalse())
+		NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusNoContent))
+		NewWithT(t).Expect(rw.Header().Get("Access-Control-Allow-Methods")).To(Equal("POST"))
+
+		serve("/unknown", ctx)
+		NewWithT(t).Expect(reached).To(BeTrue())
+
+		rw = serve("/users", context.Background())
+		NewWithT(t).Expect(reached).To(BeTrue())
+		NewWithT(t).Expect(rw.Header().Get("Access-Control-Allow-Methods")).To(BeEmpty())
+	})
+}
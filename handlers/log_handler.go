@@ -1,28 +1,133 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io"
+	"math/rand"
 	"net/http"
+	"net/url"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-courier/httptransport/httpx"
 	"github.com/go-courier/logr"
 	"github.com/go-courier/metax"
-	"github.com/google/uuid"
+	contextx "github.com/go-courier/x/context"
 	"github.com/pkg/errors"
 )
 
-func LogHandler() func(handler http.Handler) http.Handler {
+// request headers redacted in logs by default
+var DefaultRedactedHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"X-Api-Key",
+}
+
+// query parameters redacted in logs by default
+var DefaultRedactedQueries = []string{
+	"access_token",
+	"token",
+	"api_key",
+	"password",
+	"secret",
+}
+
+const redacted = "***"
+
+type LogHandlerOption func(h *loggerHandler)
+
+// WithLogLevel sets max level of access logs, default logr.ErrorLevel.
+// 5xx logged as error, 4xx and slow requests as warn, others as info.
+func WithLogLevel(level logr.Level) LogHandlerOption {
+	return func(h *loggerHandler) {
+		h.level = level
+	}
+}
+
+// WithLogLevelHeaderPolicy decides whether x-log-level of request honored.
+// by default, x-log-level only honored for more verbose than WithLogLevel.
+func WithLogLevelHeaderPolicy(honor func(req *http.Request) bool) LogHandlerOption {
+	return func(h *loggerHandler) {
+		h.honorLevelHeader = honor
+	}
+}
+
+// WithLogSampling logs successful requests by rate in (0, 1], default 1.
+// slow requests and errors never sampled out.
+func WithLogSampling(rate float64) LogHandlerOption {
+	return func(h *loggerHandler) {
+		h.sampleRate = rate
+	}
+}
+
+// WithLogSlowThreshold logs requests cost longer than threshold as warn with slow=true
+func WithLogSlowThreshold(threshold time.Duration) LogHandlerOption {
+	return func(h *loggerHandler) {
+		h.slowThreshold = threshold
+	}
+}
+
+// WithLogRequestHeaders logs request headers as request_header, values of redacted headers masked
+func WithLogRequestHeaders() LogHandlerOption {
+	return func(h *loggerHandler) {
+		h.logRequestHeaders = true
+	}
+}
+
+// WithLogRedactedHeaders overrides DefaultRedactedHeaders
+func WithLogRedactedHeaders(headers ...string) LogHandlerOption {
+	return func(h *loggerHandler) {
+		h.redactedHeaders = headers
+	}
+}
+
+// WithLogRedactedQueries overrides DefaultRedactedQueries, values masked in request_url
+func WithLogRedactedQueries(queries ...string) LogHandlerOption {
+	return func(h *loggerHandler) {
+		h.redactedQueries = queries
+	}
+}
+
+// WithLogBody captures request and response body of 4xx and 5xx, truncated to maxSize bytes
+func WithLogBody(maxSize int) LogHandlerOption {
+	return func(h *loggerHandler) {
+		h.maxBodySize = maxSize
+	}
+}
+
+func LogHandler(options ...LogHandlerOption) func(handler http.Handler) http.Handler {
 	return func(handler http.Handler) http.Handler {
-		return &loggerHandler{
-			nextHandler: handler,
+		h := &loggerHandler{
+			nextHandler:     handler,
+			level:           logr.ErrorLevel,
+			sampleRate:      1,
+			redactedHeaders: DefaultRedactedHeaders,
+			redactedQueries: DefaultRedactedQueries,
+		}
+
+		for i := range options {
+			options[i](h)
 		}
+
+		return h
 	}
 }
 
 type loggerHandler struct {
 	nextHandler http.Handler
+
+	level             logr.Level
+	honorLevelHeader  func(req *http.Request) bool
+	sampleRate        float64
+	slowThreshold     time.Duration
+	logRequestHeaders bool
+	redactedHeaders   []string
+	redactedQueries   []string
+	maxBodySize       int
 }
 
 type LoggerResponseWriter struct {
@@ -32,25 +137,45 @@ type LoggerResponseWriter struct {
 
 	statusCode int
 	err        error
+	written    int64
+	body       *truncatedBuffer
 }
 
 func (rw *LoggerResponseWriter) Header() http.Header {
 	return rw.rw.Header()
 }
 
-func (rw *LoggerResponseWriter) WriteErr(err error) {
+// Unwrap for http.ResponseController
+func (rw *LoggerResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.rw
+}
+
+// WriteError records error of response, called by HttpRouteHandler
+func (rw *LoggerResponseWriter) WriteError(err error) {
 	rw.err = err
 }
 
+func (rw *LoggerResponseWriter) WriteErr(err error) {
+	rw.WriteError(err)
+}
+
 func (rw *LoggerResponseWriter) WriteHeader(statusCode int) {
 	rw.writeHeader(statusCode)
 }
 
 func (rw *LoggerResponseWriter) Write(data []byte) (int, error) {
+	if !rw.headerWritten {
+		rw.writeHeader(http.StatusOK)
+	}
 	if rw.err != nil && rw.statusCode >= http.StatusBadRequest {
 		rw.err = errors.New(string(data))
 	}
-	return rw.rw.Write(data)
+	if rw.body != nil {
+		_, _ = rw.body.Write(data)
+	}
+	n, err := rw.rw.Write(data)
+	rw.written += int64(n)
+	return n, err
 }
 
 func (rw *LoggerResponseWriter) writeHeader(statusCode int) {
@@ -62,50 +187,220 @@ func (rw *LoggerResponseWriter) writeHeader(statusCode int) {
 }
 
 func (h *loggerHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	requestID := req.Header.Get(httpx.HeaderRequestID)
-	if requestID == "" {
-		requestID = uuid.New().String()
+	tc, ok := httpx.TraceContextFromContext(req.Context())
+	if !ok {
+		tc = httpx.TraceContextFromRequest(req)
 	}
 
 	loggerRw := &LoggerResponseWriter{rw: rw}
 
+	var requestBody *truncatedBuffer
+
+	if h.maxBodySize > 0 {
+		loggerRw.body = &truncatedBuffer{max: h.maxBodySize}
+
+		if req.Body != nil && req.Body != http.NoBody {
+			requestBody = &truncatedBuffer{max: h.maxBodySize}
+			req.Body = &teeReadCloser{Reader: io.TeeReader(req.Body, requestBody), Closer: req.Body}
+		}
+	}
+
 	startAt := time.Now()
 
 	logger := logr.FromContext(req.Context())
 
-	level, _ := logr.ParseLevel(strings.ToLower(req.Header.Get("x-log-level")))
+	level := h.levelOf(req)
+
+	fields := &logFields{}
 
 	defer func() {
 		duration := time.Since(startAt)
 
 		header := req.Header
 
-		fields := []interface{}{
+		kvs := []interface{}{
 			"tag", "access",
 			"cost", fmt.Sprintf("%0.3fms", float64(duration/time.Millisecond)),
 			"remote_ip", httpx.ClientIP(req),
 			"method", req.Method,
-			"request_url", req.URL.String(),
+			"request_url", h.redactURL(req.URL),
 			"user_agent", header.Get(httpx.HeaderUserAgent),
 			"status", loggerRw.statusCode,
+			"bytes", loggerRw.written,
+			"request_id", tc.RequestID,
+			"trace_id", tc.TraceParent.TraceID.String(),
 		}
 
-		if loggerRw.err != nil {
-			if loggerRw.statusCode >= http.StatusInternalServerError {
-				if level >= logr.ErrorLevel {
-					logger.WithValues(fields).Error(loggerRw.err)
-				}
-			} else {
-				if level >= logr.WarnLevel {
-					logger.WithValues(fields).Warn(loggerRw.err)
-				}
+		kvs = append(kvs, fields.values()...)
+
+		if h.logRequestHeaders {
+			kvs = append(kvs, "request_header", h.redactHeader(header))
+		}
+
+		slow := h.slowThreshold > 0 && duration > h.slowThreshold
+		if slow {
+			kvs = append(kvs, "slow", true)
+		}
+
+		if loggerRw.statusCode >= http.StatusBadRequest && h.maxBodySize > 0 {
+			if requestBody != nil {
+				kvs = append(kvs, "request_body", requestBody.String())
+			}
+			kvs = append(kvs, "response_body", loggerRw.body.String())
+		}
+
+		err := loggerRw.err
+		if err == nil && loggerRw.statusCode >= http.StatusBadRequest {
+			err = errors.New(http.StatusText(loggerRw.statusCode))
+		}
+
+		switch {
+		case loggerRw.statusCode >= http.StatusInternalServerError:
+			if level >= logr.ErrorLevel {
+				logger.WithValues(kvs...).Error(err)
 			}
-		} else {
-			if level >= logr.InfoLevel {
-				logger.WithValues(fields).Info("")
+		case loggerRw.statusCode >= http.StatusBadRequest:
+			if level >= logr.WarnLevel {
+				logger.WithValues(kvs...).Warn(err)
+			}
+		case slow:
+			if level >= logr.WarnLevel {
+				logger.WithValues(kvs...).Warn(errors.Errorf("slow request, cost longer than %s", h.slowThreshold))
+			}
+		default:
+			if level >= logr.InfoLevel && h.sampled() {
+				logger.WithValues(kvs...).Info("")
 			}
 		}
 	}()
 
-	h.nextHandler.ServeHTTP(loggerRw, req.WithContext(metax.ContextWithMeta(req.Context(), metax.ParseMeta(requestID))))
+	ctx := metax.ContextWithMeta(req.Context(), metax.ParseMeta(tc.RequestID))
+	ctx = contextx.WithValue(ctx, contextKeyLogFields{}, fields)
+
+	h.nextHandler.ServeHTTP(loggerRw, req.WithContext(ctx))
+}
+
+func (h *loggerHandler) levelOf(req *http.Request) logr.Level {
+	headerLevel, err := logr.ParseLevel(strings.ToLower(req.Header.Get("x-log-level")))
+	if err != nil {
+		return h.level
+	}
+
+	if h.honorLevelHeader != nil {
+		if h.honorLevelHeader(req) {
+			return headerLevel
+		}
+		return h.level
+	}
+
+	// only more verbose
+	if headerLevel > h.level {
+		return headerLevel
+	}
+	return h.level
+}
+
+func (h *loggerHandler) sampled() bool {
+	return h.sampleRate >= 1 || rand.Float64() < h.sampleRate
+}
+
+func (h *loggerHandler) redactURL(u *url.URL) string {
+	if u.RawQuery == "" || len(h.redactedQueries) == 0 {
+		return u.String()
+	}
+
+	query := u.Query()
+	changed := false
+
+	for key := range query {
+		for _, name := range h.redactedQueries {
+			if strings.EqualFold(key, name) {
+				for i := range query[key] {
+					query[key][i] = redacted
+				}
+				changed = true
+			}
+		}
+	}
+
+	if !changed {
+		return u.String()
+	}
+
+	redactedURL := *u
+	redactedURL.RawQuery = query.Encode()
+	return redactedURL.String()
+}
+
+func (h *loggerHandler) redactHeader(header http.Header) map[string]string {
+	values := make(map[string]string, len(header))
+
+	for key := range header {
+		value := strings.Join(header[key], ", ")
+		for _, name := range h.redactedHeaders {
+			if strings.EqualFold(key, name) {
+				value = redacted
+				break
+			}
+		}
+		values[key] = value
+	}
+
+	return values
+}
+
+type contextKeyLogFields struct{}
+
+// AppendLogFields appends key-value pairs to access log of LogHandler, like operation id set by HttpRouteHandler
+func AppendLogFields(ctx context.Context, keysAndValues ...interface{}) {
+	if fields, ok := ctx.Value(contextKeyLogFields{}).(*logFields); ok {
+		fields.append(keysAndValues...)
+	}
+}
+
+type logFields struct {
+	mu            sync.Mutex
+	keysAndValues []interface{}
+}
+
+func (f *logFields) append(keysAndValues ...interface{}) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.keysAndValues = append(f.keysAndValues, keysAndValues...)
+}
+
+func (f *logFields) values() []interface{} {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.keysAndValues
+}
+
+// truncatedBuffer keeps first max bytes
+type truncatedBuffer struct {
+	bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *truncatedBuffer) Write(p []byte) (int, error) {
+	if remain := b.max - b.Len(); remain < len(p) {
+		b.truncated = true
+		if remain > 0 {
+			b.Buffer.Write(p[:remain])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+func (b *truncatedBuffer) String() string {
+	if b.truncated {
+		return b.Buffer.String() + "...(truncated)"
+	}
+	return b.Buffer.String()
+}
+
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
 }
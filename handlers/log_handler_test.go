@@ -2,12 +2,17 @@ package handlers
 
 import (
 	"context"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
 	"time"
 
 	"github.com/go-courier/httptransport/testify"
 	"github.com/go-courier/logr"
 	"github.com/go-courier/logr/slog"
+	. "github.com/onsi/gomega"
 )
 
 func ExampleLogHandler() {
@@ -39,3 +44,110 @@ func ExampleLogHandler() {
 	}
 	// Output:
 }
+
+func TestLogHandler(t *testing.T) {
+	var handle http.HandlerFunc = func(rw http.ResponseWriter, req *http.Request) {
+		AppendLogFields(req.Context(), "operation_id", "Demo")
+
+		switch req.URL.Path {
+		case "/slow":
+			time.Sleep(20 * time.Millisecond)
+			rw.WriteHeader(http.StatusNoContent)
+		case "/bad":
+			_, _ = io.ReadAll(req.Body)
+			rw.WriteHeader(http.StatusBadRequest)
+			_, _ = rw.Write([]byte(`{"key":"StatusBadRequest","msg":"something wrong"}`))
+		case "/fail":
+			rw.WriteHeader(http.StatusInternalServerError)
+		default:
+			_, _ = rw.Write([]byte(`{"status":"ok"}`))
+		}
+	}
+
+	serve := func(handler http.Handler, method string, target string, body io.Reader, header http.Header) []testify.LogEntry {
+		logger := testify.NewMockLogger()
+		req := httptest.NewRequest(method, target, body)
+		for key := range header {
+			req.Header[key] = header[key]
+		}
+		req = req.WithContext(logr.WithLogger(req.Context(), logger))
+		handler.ServeHTTP(testify.NewMockResponseWriter(), req)
+		return logger.Entries()
+	}
+
+	t.Run("level by status", func(t *testing.T) {
+		handler := LogHandler(WithLogLevel(logr.InfoLevel))(handle)
+
+		entries := serve(handler, http.MethodGet, "/", nil, nil)
+		NewWithT(t).Expect(entries).To(HaveLen(1))
+		NewWithT(t).Expect(entries[0].Level).To(Equal(logr.InfoLevel))
+		NewWithT(t).Expect(entries[0].Fields["status"]).To(Equal(http.StatusOK))
+		NewWithT(t).Expect(entries[0].Fields["bytes"]).To(Equal(int64(len(`{"status":"ok"}`))))
+		NewWithT(t).Expect(entries[0].Fields["operation_id"]).To(Equal("Demo"))
+
+		entries = serve(handler, http.MethodGet, "/bad", nil, nil)
+		NewWithT(t).Expect(entries).To(HaveLen(1))
+		NewWithT(t).Expect(entries[0].Level).To(Equal(logr.WarnLevel))
+
+		entries = serve(handler, http.MethodGet, "/fail", nil, nil)
+		NewWithT(t).Expect(entries).To(HaveLen(1))
+		NewWithT(t).Expect(entries[0].Level).To(Equal(logr.ErrorLevel))
+		NewWithT(t).Expect(entries[0].Msg).To(Equal(http.StatusText(http.StatusInternalServerError)))
+	})
+
+	t.Run("x-log-level", func(t *testing.T) {
+		handler := LogHandler(WithLogLevel(logr.WarnLevel))(handle)
+
+		NewWithT(t).Expect(serve(handler, http.MethodGet, "/", nil, nil)).To(HaveLen(0))
+		NewWithT(t).Expect(serve(handler, http.MethodGet, "/", nil, http.Header{"X-Log-Level": {"info"}})).To(HaveLen(1))
+		NewWithT(t).Expect(serve(handler, http.MethodGet, "/bad", nil, http.Header{"X-Log-Level": {"error"}})).To(HaveLen(1))
+
+		t.Run("with policy", func(t *testing.T) {
+			handler := LogHandler(WithLogLevel(logr.WarnLevel), WithLogLevelHeaderPolicy(func(req *http.Request) bool {
+				return false
+			}))(handle)
+
+			NewWithT(t).Expect(serve(handler, http.MethodGet, "/", nil, http.Header{"X-Log-Level": {"info"}})).To(HaveLen(0))
+		})
+	})
+
+	t.Run("sampling", func(t *testing.T) {
+		handler := LogHandler(WithLogLevel(logr.InfoLevel), WithLogSampling(0), WithLogSlowThreshold(10*time.Millisecond))(handle)
+
+		NewWithT(t).Expect(serve(handler, http.MethodGet, "/", nil, nil)).To(HaveLen(0))
+		NewWithT(t).Expect(serve(handler, http.MethodGet, "/bad", nil, nil)).To(HaveLen(1))
+
+		entries := serve(handler, http.MethodGet, "/slow", nil, nil)
+		NewWithT(t).Expect(entries).To(HaveLen(1))
+		NewWithT(t).Expect(entries[0].Level).To(Equal(logr.WarnLevel))
+		NewWithT(t).Expect(entries[0].Fields["slow"]).To(Equal(true))
+	})
+
+	t.Run("redaction", func(t *testing.T) {
+		handler := LogHandler(WithLogLevel(logr.InfoLevel), WithLogRequestHeaders())(handle)
+
+		entries := serve(handler, http.MethodGet, "/?token=secret&size=10", nil, http.Header{
+			"Authorization": {"Bearer secret"},
+			"X-Custom":      {"value"},
+		})
+		NewWithT(t).Expect(entries).To(HaveLen(1))
+		NewWithT(t).Expect(entries[0].Fields["request_url"]).To(Equal("/?size=10&token=%2A%2A%2A"))
+
+		requestHeader := entries[0].Fields["request_header"].(map[string]string)
+		NewWithT(t).Expect(requestHeader["Authorization"]).To(Equal("***"))
+		NewWithT(t).Expect(requestHeader["X-Custom"]).To(Equal("value"))
+	})
+
+	t.Run("body of errors", func(t *testing.T) {
+		handler := LogHandler(WithLogLevel(logr.InfoLevel), WithLogBody(10))(handle)
+
+		entries := serve(handler, http.MethodPost, "/bad", strings.NewReader(`{"name":"test"}`), nil)
+		NewWithT(t).Expect(entries).To(HaveLen(1))
+		NewWithT(t).Expect(entries[0].Fields["request_body"]).To(Equal(`{"name":"t...(truncated)`))
+		NewWithT(t).Expect(entries[0].Fields["response_body"]).To(Equal(`{"key":"St...(truncated)`))
+
+		entries = serve(handler, http.MethodGet, "/", nil, nil)
+		NewWithT(t).Expect(entries).To(HaveLen(1))
+		NewWithT(t).Expect(entries[0].Fields).NotTo(HaveKey("response_body"))
+	})
+}
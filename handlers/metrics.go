@@ -0,0 +1,358 @@
+package handlers
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/go-courier/httptransport/httpx"
+	"github.com/pkg/errors"
+)
+
+// DefaultMetricsRegistry used by MetricsHandler and roundtrippers.NewMetricsRoundTripper by default
+var DefaultMetricsRegistry = NewMetricsRegistry()
+
+// DefaultDurationBuckets in seconds
+var DefaultDurationBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DefaultSizeBuckets in bytes
+var DefaultSizeBuckets = []float64{100, 1000, 10000, 100000, 1000000, 10000000}
+
+// MethodLabel returns method as label value of metrics,
+// OTHER for methods not defined in RFC 9110 or RFC 5789, so that series not unbounded by arbitrary methods of clients
+func MethodLabel(method string) string {
+	switch method {
+	case "":
+		return http.MethodGet
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return method
+	}
+	return "OTHER"
+}
+
+type metricType string
+
+const (
+	metricTypeCounter   metricType = "counter"
+	metricTypeGauge     metricType = "gauge"
+	metricTypeHistogram metricType = "histogram"
+)
+
+func NewMetricsRegistry() *MetricsRegistry {
+	return &MetricsRegistry{
+		families: map[string]*metricFamily{},
+	}
+}
+
+// MetricsRegistry collects metrics in memory,
+// and serves them in text format of Prometheus as http.Handler
+type MetricsRegistry struct {
+	mu       sync.RWMutex
+	families map[string]*metricFamily
+}
+
+// Counter registers counter, returns registered one when name registered already
+func (r *MetricsRegistry) Counter(name string, help string, labelNames ...string) *CounterVec {
+	return &CounterVec{family: r.register(name, help, metricTypeCounter, nil, labelNames)}
+}
+
+// Gauge registers gauge, returns registered one when name registered already
+func (r *MetricsRegistry) Gauge(name string, help string, labelNames ...string) *GaugeVec {
+	return &GaugeVec{family: r.register(name, help, metricTypeGauge, nil, labelNames)}
+}
+
+// Histogram registers histogram with upper bounds of buckets, returns registered one when name registered already
+func (r *MetricsRegistry) Histogram(name string, help string, buckets []float64, labelNames ...string) *HistogramVec {
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+	return &HistogramVec{family: r.register(name, help, metricTypeHistogram, buckets, labelNames)}
+}
+
+func (r *MetricsRegistry) register(name string, help string, typ metricType, buckets []float64, labelNames []string) *metricFamily {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if family, ok := r.families[name]; ok {
+		if family.typ != typ || strings.Join(family.labelNames, ",") != strings.Join(labelNames, ",") {
+			panic(errors.Errorf("metric %s registered as %s with labels %v", name, family.typ, family.labelNames))
+		}
+		return family
+	}
+
+	family := &metricFamily{
+		name:       name,
+		help:       help,
+		typ:        typ,
+		buckets:    buckets,
+		labelNames: labelNames,
+		series:     map[string]*metricSeries{},
+	}
+
+	r.families[name] = family
+
+	return family
+}
+
+func (r *MetricsRegistry) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set(httpx.HeaderContentType, httpx.MIME_PLAIN+"; version=0.0.4; charset=utf-8")
+	rw.WriteHeader(http.StatusOK)
+	_, _ = r.WriteTo(rw)
+}
+
+// WriteTo writes all metrics in text format of Prometheus, sorted by name and label values
+func (r *MetricsRegistry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.RLock()
+	families := make([]*metricFamily, 0, len(r.families))
+	for _, family := range r.families {
+		families = append(families, family)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(families, func(i, j int) bool {
+		return families[i].name < families[j].name
+	})
+
+	cw := &countingWriter{Writer: w}
+	bw := bufio.NewWriter(cw)
+
+	for _, family := range families {
+		family.writeTo(bw)
+	}
+
+	if err := bw.Flush(); err != nil {
+		return cw.n, err
+	}
+	return cw.n, nil
+}
+
+type CounterVec struct {
+	family *metricFamily
+}
+
+// Add adds v to counter of label values, v must not be negative
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		panic(errors.Errorf("counter %s cannot decrease", c.family.name))
+	}
+	c.family.with(labelValues).add(v)
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+type GaugeVec struct {
+	family *metricFamily
+}
+
+func (g *GaugeVec) Set(v float64, labelValues ...string) {
+	g.family.with(labelValues).set(v)
+}
+
+func (g *GaugeVec) Add(v float64, labelValues ...string) {
+	g.family.with(labelValues).add(v)
+}
+
+func (g *GaugeVec) Inc(labelValues ...string) {
+	g.Add(1, labelValues...)
+}
+
+func (g *GaugeVec) Dec(labelValues ...string) {
+	g.Add(-1, labelValues...)
+}
+
+type HistogramVec struct {
+	family *metricFamily
+}
+
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	h.family.with(labelValues).observe(h.family.buckets, v)
+}
+
+type metricFamily struct {
+	name       string
+	help       string
+	typ        metricType
+	buckets    []float64
+	labelNames []string
+
+	mu     sync.RWMutex
+	series map[string]*metricSeries
+}
+
+func (f *metricFamily) with(labelValues []string) *metricSeries {
+	if len(labelValues) != len(f.labelNames) {
+		panic(errors.Errorf("metric %s requires labels %v, but got values %v", f.name, f.labelNames, labelValues))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	f.mu.RLock()
+	s, ok := f.series[key]
+	f.mu.RUnlock()
+
+	if ok {
+		return s
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if s, ok := f.series[key]; ok {
+		return s
+	}
+
+	s = &metricSeries{
+		labelValues: append([]string{}, labelValues...),
+	}
+	if f.typ == metricTypeHistogram {
+		s.bucketCounts = make([]uint64, len(f.buckets))
+	}
+
+	f.series[key] = s
+
+	return s
+}
+
+func (f *metricFamily) writeTo(w *bufio.Writer) {
+	f.mu.RLock()
+	series := make([]*metricSeries, 0, len(f.series))
+	for _, s := range f.series {
+		series = append(series, s)
+	}
+	f.mu.RUnlock()
+
+	if len(series) == 0 {
+		return
+	}
+
+	sort.Slice(series, func(i, j int) bool {
+		return strings.Join(series[i].labelValues, "\xff") < strings.Join(series[j].labelValues, "\xff")
+	})
+
+	_, _ = fmt.Fprintf(w, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	_, _ = fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.typ)
+
+	for _, s := range series {
+		s.mu.Lock()
+
+		switch f.typ {
+		case metricTypeHistogram:
+			cumulative := uint64(0)
+			for i, upperBound := range f.buckets {
+				cumulative += s.bucketCounts[i]
+				writeSample(w, f.name+"_bucket", f.labelNames, s.labelValues, "le", formatFloat(upperBound), float64(cumulative))
+			}
+			writeSample(w, f.name+"_bucket", f.labelNames, s.labelValues, "le", "+Inf", float64(s.count))
+			writeSample(w, f.name+"_sum", f.labelNames, s.labelValues, "", "", s.value)
+			writeSample(w, f.name+"_count", f.labelNames, s.labelValues, "", "", float64(s.count))
+		default:
+			writeSample(w, f.name, f.labelNames, s.labelValues, "", "", s.value)
+		}
+
+		s.mu.Unlock()
+	}
+}
+
+type metricSeries struct {
+	labelValues []string
+
+	mu sync.Mutex
+	// value of counter or gauge, or sum of histogram
+	value        float64
+	count        uint64
+	bucketCounts []uint64
+}
+
+func (s *metricSeries) add(v float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.value += v
+}
+
+func (s *metricSeries) set(v float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.value = v
+}
+
+func (s *metricSeries) observe(buckets []float64, v float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.value += v
+	s.count++
+
+	// counts stored per bucket, accumulated when writing
+	if i := sort.SearchFloat64s(buckets, v); i < len(buckets) {
+		s.bucketCounts[i]++
+	}
+}
+
+func writeSample(w *bufio.Writer, name string, labelNames []string, labelValues []string, extraLabelName string, extraLabelValue string, v float64) {
+	_, _ = w.WriteString(name)
+
+	if len(labelNames) > 0 || extraLabelName != "" {
+		_ = w.WriteByte('{')
+		for i := range labelNames {
+			if i > 0 {
+				_ = w.WriteByte(',')
+			}
+			writeLabel(w, labelNames[i], labelValues[i])
+		}
+		if extraLabelName != "" {
+			if len(labelNames) > 0 {
+				_ = w.WriteByte(',')
+			}
+			writeLabel(w, extraLabelName, extraLabelValue)
+		}
+		_ = w.WriteByte('}')
+	}
+
+	_ = w.WriteByte(' ')
+	_, _ = w.WriteString(formatFloat(v))
+	_ = w.WriteByte('\n')
+}
+
+func writeLabel(w *bufio.Writer, name string, value string) {
+	_, _ = w.WriteString(name)
+	_, _ = w.WriteString(`="`)
+	_, _ = w.WriteString(labelValueEscaper.Replace(value))
+	_ = w.WriteByte('"')
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeHelp(help string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(help)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+type countingWriter struct {
+	io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type MetricsHandlerOption func(h *metricsHandler)
+
+// WithMetricsRegistry sets registry of metrics, default DefaultMetricsRegistry
+func WithMetricsRegistry(registry *MetricsRegistry) MetricsHandlerOption {
+	return func(h *metricsHandler) {
+		h.registry = registry
+	}
+}
+
+// WithMetricsDurationBuckets sets buckets of request duration in seconds, default DefaultDurationBuckets
+func WithMetricsDurationBuckets(buckets ...float64) MetricsHandlerOption {
+	return func(h *metricsHandler) {
+		h.durationBuckets = buckets
+	}
+}
+
+// MetricsHandler records RED metrics of requests, labeled by operation_id, method (by MethodLabel) and status:
+//
+//	http_server_requests_total
+//	http_server_request_duration_seconds
+//	http_server_response_size_bytes
+//	http_server_requests_in_flight (labeled by operation_id and method)
+//
+// operation_id from OperationIDFromContext, empty for unmatched routes
+func MetricsHandler(options ...MetricsHandlerOption) func(handler http.Handler) http.Handler {
+	h := &metricsHandler{
+		registry:        DefaultMetricsRegistry,
+		durationBuckets: DefaultDurationBuckets,
+	}
+
+	for i := range options {
+		options[i](h)
+	}
+
+	h.requests = h.registry.Counter("http_server_requests_total", "Total number of http requests handled.", "operation_id", "method", "status")
+	h.duration = h.registry.Histogram("http_server_request_duration_seconds", "Duration of http requests in seconds.", h.durationBuckets, "operation_id", "method", "status")
+	h.responseSize = h.registry.Histogram("http_server_response_size_bytes", "Size of http response bodies in bytes.", DefaultSizeBuckets, "operation_id", "method", "status")
+	h.inFlight = h.registry.Gauge("http_server_requests_in_flight", "Number of http requests in flight.", "operation_id", "method")
+
+	return func(handler http.Handler) http.Handler {
+		next := *h
+		next.nextHandler = handler
+		return &next
+	}
+}
+
+type metricsHandler struct {
+	nextHandler http.Handler
+
+	registry        *MetricsRegistry
+	durationBuckets []float64
+
+	requests     *CounterVec
+	duration     *HistogramVec
+	responseSize *HistogramVec
+	inFlight     *GaugeVec
+}
+
+func (h *metricsHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	startedAt := time.Now()
+	operationID := OperationIDFromContext(req.Context())
+	method := MethodLabel(req.Method)
+
+	h.inFlight.Inc(operationID, method)
+
+	metricsRw := &metricsResponseWriter{ResponseWriter: rw}
+
+	defer func() {
+		h.inFlight.Dec(operationID, method)
+
+		statusCode := metricsRw.statusCode
+		if statusCode == 0 {
+			statusCode = http.StatusOK
+		}
+		status := strconv.Itoa(statusCode)
+
+		h.requests.Inc(operationID, method, status)
+		h.duration.Observe(time.Since(startedAt).Seconds(), operationID, method, status)
+		h.responseSize.Observe(float64(metricsRw.written), operationID, method, status)
+	}()
+
+	h.nextHandler.ServeHTTP(metricsRw, req)
+}
+
+type metricsResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	written    int64
+}
+
+// Unwrap for http.ResponseController
+func (rw *metricsResponseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// WriteError passes error of response to inner writers like LoggerResponseWriter
+func (rw *metricsResponseWriter) WriteError(err error) {
+	if rwe, ok := rw.ResponseWriter.(interface{ WriteError(err error) }); ok {
+		rwe.WriteError(err)
+	}
+}
+
+func (rw *metricsResponseWriter) WriteHeader(statusCode int) {
+	if rw.statusCode == 0 {
+		rw.statusCode = statusCode
+	}
+	rw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rw *metricsResponseWriter) Write(p []byte) (int, error) {
+	if rw.statusCode == 0 {
+		rw.statusCode = http.StatusOK
+	}
+	n, err := rw.ResponseWriter.Write(p)
+	rw.written += int64(n)
+	return n, err
+}
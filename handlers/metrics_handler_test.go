@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-courier/httptransport/testify"
+	. "github.com/onsi/gomega"
+)
+
+func TestMetricsRegistry(t *testing.T) {
+	registry := NewMetricsRegistry()
+
+	registry.Counter("jobs_total", "Total jobs.", "queue").Add(2, `say "hi"`)
+	registry.Gauge("workers", "Workers.").Set(3)
+
+	latency := registry.Histogram("latency_seconds", "Latency.", []float64{1, 0.1}, "queue")
+	latency.Observe(0.05, "default")
+	latency.Observe(0.5, "default")
+	latency.Observe(5, "default")
+
+	// registered already
+	registry.Counter("jobs_total", "Total jobs.", "queue").Inc(`say "hi"`)
+
+	buf := bytes.NewBuffer(nil)
+	_, err := registry.WriteTo(buf)
+	NewWithT(t).Expect(err).To(BeNil())
+	NewWithT(t).Expect(buf.String()).To(Equal(`# HELP jobs_total Total jobs.
+# TYPE jobs_total counter
+jobs_total{queue="say \"hi\""} 3
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{queue="default",le="0.1"} 1
+latency_seconds_bucket{queue="default",le="1"} 2
+latency_seconds_bucket{queue="default",le="+Inf"} 3
+latency_seconds_sum{queue="default"} 5.55
+latency_seconds_count{queue="default"} 3
+# HELP workers Workers.
+# TYPE workers gauge
+workers 3
+`))
+
+	t.Run("conflicted", func(t *testing.T) {
+		NewWithT(t).Expect(func() {
+			registry.Gauge("jobs_total", "Total jobs.", "queue")
+		}).To(Panic())
+	})
+}
+
+func TestMetricsHandler(t *testing.T) {
+	registry := NewMetricsRegistry()
+
+	handler := MetricsHandler(WithMetricsRegistry(registry), WithMetricsDurationBuckets(1))(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/not-found" {
+			rw.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = rw.Write([]byte(`{"id":"1"}`))
+	}))
+
+	for _, path := range []string{"/1", "/1", "/not-found"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if path != "/not-found" {
+			req = req.WithContext(ContextWithOperationID(req.Context(), "GetByID"))
+		}
+		handler.ServeHTTP(testify.NewMockResponseWriter(), req)
+	}
+
+	handler.ServeHTTP(testify.NewMockResponseWriter(), httptest.NewRequest("PURGE", "/not-found", nil))
+
+	rw := httptest.NewRecorder()
+	registry.ServeHTTP(rw, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	NewWithT(t).Expect(rw.Header().Get("Content-Type")).To(Equal("text/plain; version=0.0.4; charset=utf-8"))
+
+	body := rw.Body.String()
+	NewWithT(t).Expect(body).To(ContainSubstring(`http_server_requests_total{operation_id="GetByID",method="GET",status="200"} 2` + "\n"))
+	NewWithT(t).Expect(body).To(ContainSubstring(`http_server_requests_total{operation_id="",method="GET",status="404"} 1` + "\n"))
+	NewWithT(t).Expect(body).To(ContainSubstring(`http_server_request_duration_seconds_bucket{operation_id="GetByID",method="GET",status="200",le="1"} 2` + "\n"))
+	NewWithT(t).Expect(body).To(ContainSubstring(`http_server_response_size_bytes_sum{operation_id="GetByID",method="GET",status="200"} 20` + "\n"))
+	NewWithT(t).Expect(body).To(ContainSubstring(`http_server_requests_in_flight{operation_id="GetByID",method="GET"} 0` + "\n"))
+	NewWithT(t).Expect(body).To(ContainSubstring(`http_server_requests_total{operation_id="",method="OTHER",status="404"} 1` + "\n"))
+}
@@ -0,0 +1,275 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-courier/httptransport/httpx"
+	"github.com/go-courier/statuserror"
+	"github.com/pkg/errors"
+)
+
+// LimitKeyFunc picks key of request, requests of same key share limit
+type LimitKeyFunc func(req *http.Request) string
+
+// LimitByClientIP keys by httpx.ClientIP
+func LimitByClientIP() LimitKeyFunc {
+	return func(req *http.Request) string {
+		return httpx.ClientIP(req)
+	}
+}
+
+// LimitByHeader keys by value of header, like X-Api-Key
+func LimitByHeader(name string) LimitKeyFunc {
+	return func(req *http.Request) string {
+		return req.Header.Get(name)
+	}
+}
+
+// LimitByOperationID keys by operation id of route set by HttpTransport, empty for unmatched routes
+func LimitByOperationID() LimitKeyFunc {
+	return func(req *http.Request) string {
+		return OperationIDFromContext(req.Context())
+	}
+}
+
+// LimitByKeys joins keys, like LimitByKeys(LimitByOperationID(), LimitByClientIP()) for limits of each client per route
+func LimitByKeys(keys ...LimitKeyFunc) LimitKeyFunc {
+	return func(req *http.Request) string {
+		values := make([]string, len(keys))
+		for i := range keys {
+			values[i] = keys[i](req)
+		}
+		return strings.Join(values, "/")
+	}
+}
+
+type RateLimitOption struct {
+	// tokens refilled per second
+	Rate float64
+	// capacity of bucket, default ceil of Rate
+	Burst int
+	// key of buckets, one bucket for all requests when nil
+	Key LimitKeyFunc
+}
+
+func (opt *RateLimitOption) SetDefaults() {
+	if opt.Burst == 0 {
+		opt.Burst = int(math.Ceil(opt.Rate))
+	}
+	if opt.Key == nil {
+		opt.Key = func(req *http.Request) string {
+			return ""
+		}
+	}
+}
+
+// RateLimitHandler limits requests by token bucket of each key,
+// responds StatusErr 429 with Retry-After when bucket empty.
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset set for all requests.
+// buckets shared by all handlers wrapped, so routes of group share limits unless keyed by LimitByOperationID.
+func RateLimitHandler(opt RateLimitOption) func(handler http.Handler) http.Handler {
+	opt.SetDefaults()
+
+	if opt.Rate <= 0 {
+		panic(errors.Errorf("rate of RateLimitOption should be greater than 0, but got %v", opt.Rate))
+	}
+
+	limiter := &rateLimiter{
+		RateLimitOption: opt,
+		buckets:         map[string]*tokenBucket{},
+	}
+
+	return func(handler http.Handler) http.Handler {
+		return &rateLimitHandler{
+			limiter:     limiter,
+			nextHandler: handler,
+		}
+	}
+}
+
+type rateLimitHandler struct {
+	limiter     *rateLimiter
+	nextHandler http.Handler
+}
+
+func (h *rateLimitHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	allowed, remaining, reset, retryAfter := h.limiter.take(h.limiter.Key(req), time.Now())
+
+	header := rw.Header()
+	header.Set(httpx.HeaderRateLimitLimit, strconv.Itoa(h.limiter.Burst))
+	header.Set(httpx.HeaderRateLimitRemaining, strconv.Itoa(remaining))
+	header.Set(httpx.HeaderRateLimitReset, strconv.Itoa(ceilSeconds(reset)))
+
+	if !allowed {
+		header.Set(httpx.HeaderRetryAfter, strconv.Itoa(ceilSeconds(retryAfter)))
+		ErrorWriterFromContext(req.Context())(rw, req, statuserror.Wrap(
+			errors.Errorf("rate limit exceeded, retry after %s", retryAfter),
+			http.StatusTooManyRequests,
+			"TooManyRequests",
+		))
+		return
+	}
+
+	h.nextHandler.ServeHTTP(rw, req)
+}
+
+type rateLimiter struct {
+	RateLimitOption
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// take takes one token of bucket of key,
+// returns whether allowed, remaining tokens, duration until bucket full, and duration until next token when not allowed
+func (l *rateLimiter) take(key string, now time.Time) (allowed bool, remaining int, reset time.Duration, retryAfter time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+
+	burst := float64(l.Burst)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: burst, updatedAt: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(burst, b.tokens+now.Sub(b.updatedAt).Seconds()*l.Rate)
+	b.updatedAt = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		allowed = true
+	} else {
+		retryAfter = l.durationOf(1 - b.tokens)
+	}
+
+	return allowed, int(b.tokens), l.durationOf(burst - b.tokens), retryAfter
+}
+
+func (l *rateLimiter) durationOf(tokens float64) time.Duration {
+	return time.Duration(tokens / l.Rate * float64(time.Second))
+}
+
+// sweep removes buckets refilled full at most once a minute
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if b.tokens+now.Sub(b.updatedAt).Seconds()*l.Rate >= float64(l.Burst) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+type ConcurrencyLimitOption struct {
+	// max in-flight requests of each key
+	MaxInFlight int
+	// key of counters, one counter for all requests when nil
+	Key LimitKeyFunc
+	// Retry-After of rejected requests, default 1s
+	RetryAfter time.Duration
+}
+
+func (opt *ConcurrencyLimitOption) SetDefaults() {
+	if opt.Key == nil {
+		opt.Key = func(req *http.Request) string {
+			return ""
+		}
+	}
+	if opt.RetryAfter == 0 {
+		opt.RetryAfter = time.Second
+	}
+}
+
+// ConcurrencyLimitHandler limits in-flight requests of each key,
+// responds StatusErr 503 with Retry-After when MaxInFlight reached.
+// counters shared by all handlers wrapped, like RateLimitHandler.
+func ConcurrencyLimitHandler(opt ConcurrencyLimitOption) func(handler http.Handler) http.Handler {
+	opt.SetDefaults()
+
+	if opt.MaxInFlight <= 0 {
+		panic(errors.Errorf("max in-flight of ConcurrencyLimitOption should be greater than 0, but got %d", opt.MaxInFlight))
+	}
+
+	limiter := &concurrencyLimiter{
+		ConcurrencyLimitOption: opt,
+		inFlight:               map[string]int{},
+	}
+
+	return func(handler http.Handler) http.Handler {
+		return &concurrencyLimitHandler{
+			limiter:     limiter,
+			nextHandler: handler,
+		}
+	}
+}
+
+type concurrencyLimitHandler struct {
+	limiter     *concurrencyLimiter
+	nextHandler http.Handler
+}
+
+func (h *concurrencyLimitHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	key := h.limiter.Key(req)
+
+	if !h.limiter.acquire(key) {
+		rw.Header().Set(httpx.HeaderRetryAfter, strconv.Itoa(ceilSeconds(h.limiter.RetryAfter)))
+		ErrorWriterFromContext(req.Context())(rw, req, statuserror.Wrap(
+			errors.Errorf("too many in-flight requests, max %d", h.limiter.MaxInFlight),
+			http.StatusServiceUnavailable,
+			"TooManyInFlightRequests",
+		))
+		return
+	}
+	defer h.limiter.release(key)
+
+	h.nextHandler.ServeHTTP(rw, req)
+}
+
+type concurrencyLimiter struct {
+	ConcurrencyLimitOption
+
+	mu       sync.Mutex
+	inFlight map[string]int
+}
+
+func (l *concurrencyLimiter) acquire(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight[key] >= l.MaxInFlight {
+		return false
+	}
+	l.inFlight[key]++
+	return true
+}
+
+func (l *concurrencyLimiter) release(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight[key]--; l.inFlight[key] <= 0 {
+		delete(l.inFlight, key)
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-courier/httptransport/testify"
+	. "github.com/onsi/gomega"
+)
+
+func TestRateLimitHandler(t *testing.T) {
+	handler := RateLimitHandler(RateLimitOption{
+		Rate:  1,
+		Burst: 2,
+		Key:   LimitByHeader("X-Api-Key"),
+	})(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+
+	serve := func(apiKey string) *testify.MockResponseWriter {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Api-Key", apiKey)
+		rw := testify.NewMockResponseWriter()
+		handler.ServeHTTP(rw, req)
+		return rw
+	}
+
+	rw := serve("a")
+	NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusNoContent))
+	NewWithT(t).Expect(rw.Header().Get("RateLimit-Limit")).To(Equal("2"))
+	NewWithT(t).Expect(rw.Header().Get("RateLimit-Remaining")).To(Equal("1"))
+	NewWithT(t).Expect(rw.Header().Get("RateLimit-Reset")).To(Equal("1"))
+
+	NewWithT(t).Expect(serve("a").StatusCode).To(Equal(http.StatusNoContent))
+
+	rw = serve("a")
+	NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusTooManyRequests))
+	NewWithT(t).Expect(rw.Header().Get("Retry-After")).To(Equal("1"))
+	NewWithT(t).Expect(rw.Header().Get("RateLimit-Remaining")).To(Equal("0"))
+	NewWithT(t).Expect(string(rw.MustDumpResponse())).To(ContainSubstring(`"key":"TooManyRequests","code":429000000`))
+
+	// other key
+	NewWithT(t).Expect(serve("b").StatusCode).To(Equal(http.StatusNoContent))
+}
+
+func TestTokenBucket(t *testing.T) {
+	opt := RateLimitOption{Rate: 2, Burst: 2}
+	opt.SetDefaults()
+
+	limiter := &rateLimiter{RateLimitOption: opt, buckets: map[string]*tokenBucket{}}
+
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		allowed, _, _, _ := limiter.take("", now)
+		NewWithT(t).Expect(allowed).To(BeTrue())
+	}
+
+	allowed, remaining, reset, retryAfter := limiter.take("", now)
+	NewWithT(t).Expect(allowed).To(BeFalse())
+	NewWithT(t).Expect(remaining).To(Equal(0))
+	NewWithT(t).Expect(reset).To(Equal(time.Second))
+	NewWithT(t).Expect(retryAfter).To(Equal(500 * time.Millisecond))
+
+	allowed, _, _, _ = limiter.take("", now.Add(500*time.Millisecond))
+	NewWithT(t).Expect(allowed).To(BeTrue())
+
+	t.Run("sweep full buckets", func(t *testing.T) {
+		limiter.take("", now.Add(2*time.Minute))
+		limiter.take("other", now.Add(2*time.Minute))
+		NewWithT(t).Expect(limiter.buckets).To(HaveLen(2))
+
+		limiter.take("other", now.Add(4*time.Minute))
+		NewWithT(t).Expect(limiter.buckets).To(HaveKey("other"))
+		NewWithT(t).Expect(limiter.buckets).NotTo(HaveKey(""))
+	})
+}
+
+func TestConcurrencyLimitHandler(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	handler := ConcurrencyLimitHandler(ConcurrencyLimitOption{
+		MaxInFlight: 1,
+		Key:         LimitByKeys(LimitByOperationID(), LimitByClientIP()),
+	})(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/slow" {
+			close(started)
+			<-release
+		}
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+
+	serve := func(path string, operationID string) *testify.MockResponseWriter {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req = req.WithContext(ContextWithOperationID(req.Context(), operationID))
+		rw := testify.NewMockResponseWriter()
+		handler.ServeHTTP(rw, req)
+		return rw
+	}
+
+	done := make(chan *testify.MockResponseWriter)
+	go func() {
+		done <- serve("/slow", "Slow")
+	}()
+	<-started
+
+	rw := serve("/", "Slow")
+	NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusServiceUnavailable))
+	NewWithT(t).Expect(rw.Header().Get("Retry-After")).To(Equal("1"))
+	NewWithT(t).Expect(string(rw.MustDumpResponse())).To(ContainSubstring(`"key":"TooManyInFlightRequests","code":503000000`))
+
+	// other operation
+	NewWithT(t).Expect(serve("/", "Fast").StatusCode).To(Equal(http.StatusNoContent))
+
+	close(release)
+	NewWithT(t).Expect((<-done).StatusCode).To(Equal(http.StatusNoContent))
+
+	NewWithT(t).Expect(serve("/", "Slow").StatusCode).To(Equal(http.StatusNoContent))
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-courier/httptransport/httpx"
+)
+
+// TraceContextHandler parses X-Request-ID, traceparent and tracestate of request into context,
+// and echoes them in response.
+// downstream requests of client.Client.Do with the context carry them automatically.
+func TraceContextHandler() func(handler http.Handler) http.Handler {
+	return func(handler http.Handler) http.Handler {
+		return &traceContextHandler{
+			nextHandler: handler,
+		}
+	}
+}
+
+type traceContextHandler struct {
+	nextHandler http.Handler
+}
+
+func (h *traceContextHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	tc := httpx.TraceContextFromRequest(req)
+
+	tc.InjectTo(rw.Header())
+
+	h.nextHandler.ServeHTTP(rw, req.WithContext(httpx.ContextWithTraceContext(req.Context(), tc)))
+}
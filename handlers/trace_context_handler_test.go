@@ -0,0 +1,35 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-courier/httptransport/httpx"
+	"github.com/go-courier/httptransport/testify"
+	. "github.com/onsi/gomega"
+)
+
+func TestTraceContextHandler(t *testing.T) {
+	var tc httpx.TraceContext
+
+	handler := TraceContextHandler()(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		tc, _ = httpx.TraceContextFromContext(req.Context())
+		rw.WriteHeader(http.StatusNoContent)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(httpx.HeaderRequestID, "request-1")
+	req.Header.Set(httpx.HeaderTraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set(httpx.HeaderTraceState, "congo=t61rcWkgMzE")
+
+	rw := testify.NewMockResponseWriter()
+	handler.ServeHTTP(rw, req)
+
+	NewWithT(t).Expect(tc.RequestID).To(Equal("request-1"))
+	NewWithT(t).Expect(tc.TraceParent.TraceID.String()).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+
+	NewWithT(t).Expect(rw.Header().Get(httpx.HeaderRequestID)).To(Equal("request-1"))
+	NewWithT(t).Expect(rw.Header().Get(httpx.HeaderTraceParent)).To(Equal(tc.TraceParent.String()))
+	NewWithT(t).Expect(rw.Header().Get(httpx.HeaderTraceState)).To(Equal("congo=t61rcWkgMzE"))
+}
@@ -0,0 +1,150 @@
+package httptransport
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/go-courier/httptransport/transformers"
+	"github.com/go-courier/statuserror"
+	"github.com/pkg/errors"
+)
+
+// BodyLimits limits reading and decoding of request body, zero value means no limit.
+type BodyLimits struct {
+	// max bytes of request body, exceeded as 413
+	MaxBodySize int64
+	// max duration of reading request body, exceeded as 408
+	DecodeTimeout time.Duration
+	// limits of multipart/form-data, exceeded as 413
+	Multipart transformers.MultipartLimits
+}
+
+// Merge overrides with non-zero fields of limits
+func (l BodyLimits) Merge(limits BodyLimits) BodyLimits {
+	if limits.MaxBodySize != 0 {
+		l.MaxBodySize = limits.MaxBodySize
+	}
+	if limits.DecodeTimeout != 0 {
+		l.DecodeTimeout = limits.DecodeTimeout
+	}
+	if limits.Multipart.MaxMemory != 0 {
+		l.Multipart.MaxMemory = limits.Multipart.MaxMemory
+	}
+	if limits.Multipart.MaxFiles != 0 {
+		l.Multipart.MaxFiles = limits.Multipart.MaxFiles
+	}
+	if limits.Multipart.MaxFileSize != 0 {
+		l.Multipart.MaxFileSize = limits.Multipart.MaxFileSize
+	}
+	return l
+}
+
+// BodyLimitsDescriber operator (or MetaOperator of Group or BasePath) with BodyLimits,
+// which overrides BodyLimits of HttpTransport for each route containing it
+type BodyLimitsDescriber interface {
+	BodyLimits() BodyLimits
+}
+
+// BodyLimits returns limits overridden by operators in route, inner wins
+func (route *HttpRouteMeta) BodyLimits(limits BodyLimits) BodyLimits {
+	for _, m := range route.OperatorFactoryWithRouteMetas {
+		if bodyLimitsDescriber, ok := m.Operator.(BodyLimitsDescriber); ok {
+			limits = limits.Merge(bodyLimitsDescriber.BodyLimits())
+		}
+	}
+	return limits
+}
+
+func errRequestEntityTooLarge(err error) error {
+	return statuserror.Wrap(err, http.StatusRequestEntityTooLarge, "RequestEntityTooLarge", "request entity too large")
+}
+
+func errRequestTimeout(err error) error {
+	return statuserror.Wrap(err, http.StatusRequestTimeout, "RequestTimeout", "request timeout")
+}
+
+// limitBody applies limits to body of request
+func limitBody(rw http.ResponseWriter, r *http.Request, limits BodyLimits) error {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil
+	}
+
+	if limits.MaxBodySize <= 0 && limits.DecodeTimeout <= 0 {
+		return nil
+	}
+
+	body := &limitedBody{ReadCloser: r.Body}
+
+	if limits.MaxBodySize > 0 {
+		if r.ContentLength > limits.MaxBodySize {
+			return errRequestEntityTooLarge(errors.Errorf("request body should not be larger than %d bytes", limits.MaxBodySize))
+		}
+		body.ReadCloser = http.MaxBytesReader(rw, r.Body, limits.MaxBodySize)
+	}
+
+	if limits.DecodeTimeout > 0 {
+		rc := http.NewResponseController(rw)
+		// not all of ResponseWriter support, like ResponseWriter for testing.
+		if err := rc.SetReadDeadline(time.Now().Add(limits.DecodeTimeout)); err == nil {
+			body.resetDeadline = func() {
+				_ = rc.SetReadDeadline(time.Time{})
+			}
+		}
+	}
+
+	r.Body = body
+
+	return nil
+}
+
+// resetDecodeDeadline clears read deadline of DecodeTimeout when decoding finished,
+// otherwise background reading of net/http hits the deadline and cancels context of request.
+func resetDecodeDeadline(r *http.Request) {
+	if body, ok := r.Body.(*limitedBody); ok {
+		body.clearDeadline()
+	}
+}
+
+// limitedBody keeps the error of exceeding limits, which may be wrapped by transformers
+type limitedBody struct {
+	io.ReadCloser
+	err           error
+	resetDeadline func()
+}
+
+func (body *limitedBody) clearDeadline() {
+	if body.resetDeadline != nil {
+		body.resetDeadline()
+		body.resetDeadline = nil
+	}
+}
+
+func (body *limitedBody) Read(p []byte) (int, error) {
+	n, err := body.ReadCloser.Read(p)
+	if err != nil {
+		// io.EOF included
+		body.clearDeadline()
+	}
+	if err != nil && body.err == nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			body.err = errRequestEntityTooLarge(errors.Errorf("request body should not be larger than %d bytes", maxBytesErr.Limit))
+		} else if errors.Is(err, os.ErrDeadlineExceeded) {
+			body.err = errRequestTimeout(errors.Wrap(err, "read request body"))
+		}
+	}
+	return n, err
+}
+
+// bodyLimitErr returns the error of exceeding limits when decoding body failed
+func bodyLimitErr(body io.Reader, err error) error {
+	if b, ok := body.(*limitedBody); ok && b.err != nil {
+		return b.err
+	}
+	if errors.Is(err, transformers.ErrMultipartLimitExceeded) {
+		return errRequestEntityTooLarge(err)
+	}
+	return nil
+}
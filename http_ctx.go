@@ -2,10 +2,12 @@ package httptransport
 
 import (
 	"context"
+	"crypto/x509"
 	"net/http"
 	"os"
 
 	"github.com/go-courier/courier"
+	"github.com/go-courier/httptransport/handlers"
 
 	contextx "github.com/go-courier/x/context"
 )
@@ -43,6 +45,21 @@ func HttpRequestFromContext(ctx context.Context) *http.Request {
 	return p
 }
 
+// ClientCertificateFromContext returns the verified client certificate of mutual TLS,
+// nil when no client certificate verified.
+func ClientCertificateFromContext(ctx context.Context) *x509.Certificate {
+	req := HttpRequestFromContext(ctx)
+	if req == nil || req.TLS == nil {
+		return nil
+	}
+	for _, chain := range req.TLS.VerifiedChains {
+		if len(chain) > 0 {
+			return chain[0]
+		}
+	}
+	return nil
+}
+
 type contextKeyServiceMetaKey struct{}
 
 func ContextWithServiceMeta(ctx context.Context, meta ServiceMeta) context.Context {
@@ -54,14 +71,12 @@ func ServerMetaFromContext(ctx context.Context) ServiceMeta {
 	return p
 }
 
-type contextKeyOperationID struct{}
-
 func ContextWithOperationID(ctx context.Context, operationID string) context.Context {
-	return contextx.WithValue(ctx, contextKeyOperationID{}, operationID)
+	return handlers.ContextWithOperationID(ctx, operationID)
 }
 
 func OperationIDFromContext(ctx context.Context) string {
-	return ctx.Value(contextKeyOperationID{}).(string)
+	return handlers.OperationIDFromContext(ctx)
 }
 
 type contextKeyOperatorFactory struct{}
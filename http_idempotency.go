@@ -0,0 +1,325 @@
+package httptransport
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-courier/httptransport/httpx"
+	"github.com/go-courier/logr"
+	"github.com/go-courier/statuserror"
+	"github.com/pkg/errors"
+)
+
+var (
+	ErrIdempotencyKeyInFlight = errors.New("request of same idempotency key in flight")
+	ErrIdempotencyKeyReused   = errors.New("idempotency key reused by different request")
+)
+
+const defaultIdempotencyTTL = 24 * time.Hour
+
+// Idempotency replays the first response of unsafe requests with same Idempotency-Key header
+// https://datatracker.ietf.org/doc/draft-ietf-httpapi-idempotency-key-header/
+type Idempotency struct {
+	// ttl of stored responses, default 24h
+	TTL time.Duration
+	// 400 when Idempotency-Key missing, otherwise processed as usual
+	Required bool
+	// scope of keys, like id of user, to avoid replaying responses of others.
+	// called with context after middle operators.
+	Scope func(ctx context.Context) string
+}
+
+// IdempotencyDescriber operator (or MetaOperator of Group or BasePath) enables Idempotency,
+// which applied to each route containing it, inner wins
+type IdempotencyDescriber interface {
+	Idempotency() *Idempotency
+}
+
+// Idempotency returns Idempotency enabled by operators in route, nil when not enabled
+func (route *HttpRouteMeta) Idempotency() *Idempotency {
+	var idempotency *Idempotency
+	for _, m := range route.OperatorFactoryWithRouteMetas {
+		if idempotencyDescriber, ok := m.Operator.(IdempotencyDescriber); ok {
+			if i := idempotencyDescriber.Idempotency(); i != nil {
+				idempotency = i
+			}
+		}
+	}
+	return idempotency
+}
+
+// IdempotentResponse stored for replaying
+type IdempotentResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// WriteTo replays response, headers of current request (like X-Request-ID) win over stored ones
+func (resp *IdempotentResponse) WriteTo(rw http.ResponseWriter) error {
+	header := rw.Header()
+	for key, values := range resp.Header {
+		if _, ok := header[key]; !ok {
+			header[key] = values
+		}
+	}
+	header.Set(httpx.HeaderIdempotentReplayed, "true")
+
+	rw.WriteHeader(resp.StatusCode)
+	_, err := rw.Write(resp.Body)
+	return err
+}
+
+// IdempotencyStore stores responses by keys, should be shared by instances of service
+type IdempotencyStore interface {
+	// Acquire locks key for request of fingerprint.
+	// returns stored response when completed before,
+	// ErrIdempotencyKeyInFlight when locked by other request,
+	// ErrIdempotencyKeyReused when used by request of other fingerprint.
+	Acquire(ctx context.Context, key string, fingerprint string, ttl time.Duration) (*IdempotentResponse, error)
+	// Save stores response of key and unlocks
+	Save(ctx context.Context, key string, response *IdempotentResponse, ttl time.Duration) error
+	// Release unlocks key without response for retrying
+	Release(ctx context.Context, key string) error
+}
+
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{
+		records: map[string]*idempotencyRecord{},
+	}
+}
+
+// MemoryIdempotencyStore for single instance
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	records   map[string]*idempotencyRecord
+	lastSweep time.Time
+}
+
+type idempotencyRecord struct {
+	fingerprint string
+	response    *IdempotentResponse
+	expiresAt   time.Time
+}
+
+func (s *MemoryIdempotencyStore) Acquire(ctx context.Context, key string, fingerprint string, ttl time.Duration) (*IdempotentResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	s.sweep(now)
+
+	if record, ok := s.records[key]; ok && now.Before(record.expiresAt) {
+		if record.fingerprint != fingerprint {
+			return nil, ErrIdempotencyKeyReused
+		}
+		if record.response == nil {
+			return nil, ErrIdempotencyKeyInFlight
+		}
+		return record.response, nil
+	}
+
+	s.records[key] = &idempotencyRecord{
+		fingerprint: fingerprint,
+		expiresAt:   now.Add(ttl),
+	}
+
+	return nil, nil
+}
+
+func (s *MemoryIdempotencyStore) Save(ctx context.Context, key string, response *IdempotentResponse, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[key]
+	if !ok {
+		return errors.Errorf("idempotency key %s not acquired", key)
+	}
+
+	record.response = response
+	record.expiresAt = time.Now().Add(ttl)
+
+	return nil
+}
+
+func (s *MemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[key]; ok && record.response == nil {
+		delete(s.records, key)
+	}
+
+	return nil
+}
+
+// sweep removes expired records at most once a minute
+func (s *MemoryIdempotencyStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < time.Minute {
+		return
+	}
+	s.lastSweep = now
+
+	for key, record := range s.records {
+		if !now.Before(record.expiresAt) {
+			delete(s.records, key)
+		}
+	}
+}
+
+// idempotencyFingerprint hashes method, path and decoded operator of request
+func idempotencyFingerprint(r *http.Request, op interface{}) (string, error) {
+	data, err := json.Marshal(op)
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(data)
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// idempotent replays stored response or returns recorder for storing response,
+// handled when response written.
+func (handler *HttpRouteHandler) idempotent(ctx context.Context, rw http.ResponseWriter, r *http.Request, operationID string, op interface{}) (recorder *idempotencyRecorder, handled bool) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return nil, false
+	}
+
+	idempotencyKey := r.Header.Get(httpx.HeaderIdempotencyKey)
+	if idempotencyKey == "" {
+		if handler.idempotency.Required {
+			handler.writeErr(rw, r, statuserror.Wrap(
+				errors.Errorf("missing header %s", httpx.HeaderIdempotencyKey),
+				http.StatusBadRequest,
+				"IdempotencyKeyRequired",
+			))
+			return nil, true
+		}
+		return nil, false
+	}
+
+	fingerprint, err := idempotencyFingerprint(r, op)
+	if err != nil {
+		handler.writeErr(rw, r, statuserror.Wrap(err, http.StatusInternalServerError, "IdempotencyFingerprintFailed"))
+		return nil, true
+	}
+
+	key := operationID + "/"
+	if handler.idempotency.Scope != nil {
+		key += handler.idempotency.Scope(ctx) + "/"
+	}
+	key += idempotencyKey
+
+	ttl := handler.idempotency.TTL
+	if ttl == 0 {
+		ttl = defaultIdempotencyTTL
+	}
+
+	stored, err := handler.idempotencyStore.Acquire(ctx, key, fingerprint, ttl)
+	if err != nil {
+		switch err {
+		case ErrIdempotencyKeyInFlight:
+			handler.writeErr(rw, r, statuserror.Wrap(err, http.StatusConflict, "IdempotencyKeyInFlight"))
+		case ErrIdempotencyKeyReused:
+			handler.writeErr(rw, r, statuserror.Wrap(err, http.StatusUnprocessableEntity, "IdempotencyKeyReused"))
+		default:
+			handler.writeErr(rw, r, statuserror.Wrap(err, http.StatusInternalServerError, "IdempotencyStoreFailed"))
+		}
+		return nil, true
+	}
+
+	if stored != nil {
+		if err := stored.WriteTo(rw); err != nil {
+			logr.FromContext(ctx).Warn(errors.Wrap(err, "replay idempotent response failed"))
+		}
+		return nil, true
+	}
+
+	return &idempotencyRecorder{
+		ResponseWriter:  rw,
+		store:           handler.idempotencyStore,
+		key:             key,
+		ttl:             ttl,
+		headerOfRequest: rw.Header().Clone(),
+	}, false
+}
+
+// idempotencyRecorder records response for storing
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	store IdempotencyStore
+	key   string
+	ttl   time.Duration
+
+	// headers set before operator, like X-Request-ID and X-Meta, which are different by requests
+	headerOfRequest http.Header
+
+	statusCode int
+	header     http.Header
+	body       bytes.Buffer
+}
+
+func (rw *idempotencyRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+func (rw *idempotencyRecorder) WriteError(err error) {
+	if rwe, ok := rw.ResponseWriter.(ResponseWithError); ok {
+		rwe.WriteError(err)
+	}
+}
+
+func (rw *idempotencyRecorder) WriteHeader(statusCode int) {
+	if rw.statusCode == 0 {
+		rw.statusCode = statusCode
+		rw.header = http.Header{}
+
+		// stores headers of response only
+		for key, values := range rw.ResponseWriter.Header() {
+			if valuesOfRequest, ok := rw.headerOfRequest[key]; ok && strings.Join(valuesOfRequest, ",") == strings.Join(values, ",") {
+				continue
+			}
+			rw.header[key] = append([]string{}, values...)
+		}
+	}
+	rw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (rw *idempotencyRecorder) Write(p []byte) (int, error) {
+	if rw.statusCode == 0 {
+		rw.WriteHeader(http.StatusOK)
+	}
+	rw.body.Write(p)
+	return rw.ResponseWriter.Write(p)
+}
+
+// done saves response, or releases key for retrying when nothing written (like panicked) or server errors
+func (rw *idempotencyRecorder) done(ctx context.Context) {
+	if rw.statusCode == 0 || rw.statusCode >= http.StatusInternalServerError {
+		if err := rw.store.Release(ctx, rw.key); err != nil {
+			logr.FromContext(ctx).Warn(errors.Wrap(err, "release idempotency key failed"))
+		}
+		return
+	}
+
+	err := rw.store.Save(ctx, rw.key, &IdempotentResponse{
+		StatusCode: rw.statusCode,
+		Header:     rw.header,
+		Body:       rw.body.Bytes(),
+	}, rw.ttl)
+	if err != nil {
+		logr.FromContext(ctx).Warn(errors.Wrap(err, "save idempotent response failed"))
+	}
+}
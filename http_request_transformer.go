@@ -300,6 +300,10 @@ func (t *RequestTransformer) DecodeFromRequestInfo(ctx context.Context, info htt
 			if param.In == "body" {
 				body := info.Body()
 				if err := param.Transformer.DecodeFrom(ctx, body, param.FieldValue(rv).Addr(), textproto.MIMEHeader(info.Header())); err != nil && err != io.EOF {
+					if e := bodyLimitErr(body, err); e != nil {
+						body.Close()
+						return e
+					}
 					errSet.AddErr(err, validator.Location(param.In))
 				}
 				body.Close()
@@ -2,11 +2,18 @@ package httptransport
 
 import (
 	"context"
+	"io"
 	"net/http"
 	"reflect"
+	"runtime/debug"
+	"strings"
+	"time"
 
+	"github.com/go-courier/courier"
+	"github.com/go-courier/httptransport/handlers"
 	"github.com/go-courier/httptransport/httpx"
 	"github.com/go-courier/httptransport/transformers"
+	"github.com/go-courier/logr"
 	"github.com/go-courier/metax"
 	"github.com/go-courier/statuserror"
 	contextx "github.com/go-courier/x/context"
@@ -14,7 +21,51 @@ import (
 	"github.com/pkg/errors"
 )
 
-func NewHttpRouteHandler(serviceMeta *ServiceMeta, httpRoute *HttpRouteMeta, requestTransformerMgr *RequestTransformerMgr) *HttpRouteHandler {
+type HttpRouteHandlerOption func(handler *HttpRouteHandler)
+
+func WithPanicRecovery(panicRecovery PanicRecovery) HttpRouteHandlerOption {
+	return func(handler *HttpRouteHandler) {
+		handler.panicRecovery = panicRecovery
+	}
+}
+
+// WithBodyLimits sets default BodyLimits, which could be overridden by BodyLimitsDescriber
+func WithBodyLimits(bodyLimits BodyLimits) HttpRouteHandlerOption {
+	return func(handler *HttpRouteHandler) {
+		handler.bodyLimits = bodyLimits
+	}
+}
+
+// WithErrorFormat sets format of error responses
+func WithErrorFormat(errorFormat ErrorFormat) HttpRouteHandlerOption {
+	return func(handler *HttpRouteHandler) {
+		handler.errorFormat = errorFormat
+	}
+}
+
+// WithContentNegotiation enables negotiating media type of responses by Accept,
+// responses in default media type of value when disabled
+func WithContentNegotiation(enabled bool) HttpRouteHandlerOption {
+	return func(handler *HttpRouteHandler) {
+		handler.contentNegotiation = enabled
+	}
+}
+
+// WithIdempotencyStore sets store for operators with IdempotencyDescriber, in-memory store used when not set
+func WithIdempotencyStore(idempotencyStore IdempotencyStore) HttpRouteHandlerOption {
+	return func(handler *HttpRouteHandler) {
+		handler.idempotencyStore = idempotencyStore
+	}
+}
+
+// WithTracer enables spans of route and each operator
+func WithTracer(tracer Tracer) HttpRouteHandlerOption {
+	return func(handler *HttpRouteHandler) {
+		handler.tracer = tracer
+	}
+}
+
+func NewHttpRouteHandler(serviceMeta *ServiceMeta, httpRoute *HttpRouteMeta, requestTransformerMgr *RequestTransformerMgr, options ...HttpRouteHandlerOption) *HttpRouteHandler {
 	operatorFactories := httpRoute.OperatorFactoryWithRouteMetas
 
 	if len(operatorFactories) == 0 {
@@ -32,13 +83,25 @@ func NewHttpRouteHandler(serviceMeta *ServiceMeta, httpRoute *HttpRouteMeta, req
 		requestTransformers[i] = rt
 	}
 
-	return &HttpRouteHandler{
+	handler := &HttpRouteHandler{
 		RequestTransformerMgr: requestTransformerMgr,
 		HttpRouteMeta:         httpRoute,
 
 		serviceMeta:         serviceMeta,
 		requestTransformers: requestTransformers,
 	}
+
+	for i := range options {
+		options[i](handler)
+	}
+
+	handler.bodyLimits = httpRoute.BodyLimits(handler.bodyLimits)
+
+	if handler.idempotency = httpRoute.Idempotency(); handler.idempotency != nil && handler.idempotencyStore == nil {
+		handler.idempotencyStore = NewMemoryIdempotencyStore()
+	}
+
+	return handler
 }
 
 type HttpRouteHandler struct {
@@ -46,6 +109,51 @@ type HttpRouteHandler struct {
 	*HttpRouteMeta
 	serviceMeta         *ServiceMeta
 	requestTransformers []*RequestTransformer
+	panicRecovery       PanicRecovery
+	bodyLimits          BodyLimits
+	errorFormat         ErrorFormat
+	contentNegotiation  bool
+	idempotency         *Idempotency
+	idempotencyStore    IdempotencyStore
+	tracer              Tracer
+}
+
+// PanicRecovery converts panics of operators to StatusErr 500
+type PanicRecovery struct {
+	// includes stack in logs, never in response
+	LogStack bool
+	// hook for forwarding panics, like to error tracker
+	OnPanic func(ctx context.Context, err error, stack []byte)
+}
+
+func (handler *HttpRouteHandler) recover(ctx context.Context, rw http.ResponseWriter, r *http.Request) {
+	p := recover()
+	if p == nil {
+		return
+	}
+
+	if p == http.ErrAbortHandler {
+		panic(p)
+	}
+
+	stack := debug.Stack()
+
+	err, ok := p.(error)
+	if !ok {
+		err = errors.Errorf("%v", p)
+	}
+
+	l := logr.FromContext(ctx)
+	if handler.panicRecovery.LogStack {
+		l = l.WithValues("stack", string(stack))
+	}
+	l.Error(errors.Wrap(err, "operator panic"))
+
+	if handler.panicRecovery.OnPanic != nil {
+		handler.panicRecovery.OnPanic(ctx, err, stack)
+	}
+
+	handler.writeErr(rw, r, statuserror.Wrap(err, http.StatusInternalServerError, "OperatorPanic", "internal server error", "operator panicked"))
 }
 
 func (handler *HttpRouteHandler) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
@@ -57,6 +165,8 @@ func (handler *HttpRouteHandler) ServeHTTP(rw http.ResponseWriter, r *http.Reque
 	ctx = ContextWithServiceMeta(ctx, *handler.serviceMeta)
 	ctx = ContextWithOperationID(ctx, operationID)
 
+	handlers.AppendLogFields(ctx, "operation_id", operationID)
+
 	spanName := handler.serviceMeta.String() + "/" + operationID
 
 	ctx = metax.ContextWithMeta(ctx, metax.Meta{
@@ -65,6 +175,30 @@ func (handler *HttpRouteHandler) ServeHTTP(rw http.ResponseWriter, r *http.Reque
 
 	rw.Header().Set("X-Meta", spanName)
 
+	var trace *routeTrace
+
+	if handler.tracer != nil {
+		ctx, trace = startRouteTrace(ctx, handler.tracer, rw, spanName)
+		trace.span.SetAttributes(
+			"http.method", r.Method,
+			"http.route", handler.Path(),
+			"operation_id", operationID,
+		)
+		rw = trace
+		defer trace.end()
+	}
+
+	defer handler.recover(ctx, rw, r)
+
+	if err := limitBody(rw, r, handler.bodyLimits); err != nil {
+		handler.writeErr(rw, r, err)
+		return
+	}
+
+	if handler.bodyLimits.Multipart != (transformers.MultipartLimits{}) {
+		ctx = transformers.ContextWithMultipartLimits(ctx, handler.bodyLimits.Multipart)
+	}
+
 	requestInfo := httpx.NewRequestInfo(r)
 
 	for i := range handler.OperatorFactoryWithRouteMetas {
@@ -78,16 +212,39 @@ func (handler *HttpRouteHandler) ServeHTTP(rw http.ResponseWriter, r *http.Reque
 
 		ctx = ContextWithOperatorFactory(ctx, opFactory.OperatorFactory)
 
+		opCtx := trace.startOperator(ctx, opFactory.String())
+
 		rt := handler.requestTransformers[i]
 		if rt != nil {
-			err := rt.DecodeAndValidate(ctx, requestInfo, op)
+			decodeStartedAt := time.Now()
+			err := rt.DecodeAndValidate(opCtx, requestInfo, op)
+			trace.setOperatorAttributes("decode.duration_ms", millisecondsSince(decodeStartedAt))
 			if err != nil {
+				resetDecodeDeadline(r)
 				handler.writeErr(rw, r, err)
 				return
 			}
 		}
 
-		result, err := op.Output(ctx)
+		if opFactory.IsLast {
+			// DecodeTimeout limits decoding only, not output of operator
+			resetDecodeDeadline(r)
+		}
+
+		if opFactory.IsLast && handler.idempotency != nil {
+			recorder, handled := handler.idempotent(ctx, rw, r, operationID, op)
+			if handled {
+				return
+			}
+			if recorder != nil {
+				rw = recorder
+				defer recorder.done(ctx)
+			}
+		}
+
+		outputStartedAt := time.Now()
+		result, err := op.Output(opCtx)
+		trace.setOperatorAttributes("output.duration_ms", millisecondsSince(outputStartedAt))
 
 		if err != nil {
 			handler.writeErr(rw, r, err)
@@ -96,7 +253,8 @@ func (handler *HttpRouteHandler) ServeHTTP(rw http.ResponseWriter, r *http.Reque
 
 		if !opFactory.IsLast {
 			if c, ok := result.(context.Context); ok {
-				ctx = c
+				// c may be derived from context with span of operator
+				ctx = trace.withRouteSpan(c)
 			} else {
 				// set result in context with key of operator name
 				ctx = contextx.WithValue(ctx, opFactory.ContextKey, result)
@@ -109,39 +267,129 @@ func (handler *HttpRouteHandler) ServeHTTP(rw http.ResponseWriter, r *http.Reque
 }
 
 func (handler *HttpRouteHandler) resolveTransformer(response *httpx.Response) (httpx.Encode, error) {
-	transformer, err := handler.TransformerMgr.NewTransformer(context.Background(), typesutil.FromRType(reflect.TypeOf(response.Value)), transformers.TransformerOption{
-		MIME: response.ContentType,
-	})
-	if err != nil {
-		return nil, err
-	}
-	return transformer.EncodeTo, nil
+	return resolveTransformerBy(handler.TransformerMgr)(response)
 }
 
 func (handler *HttpRouteHandler) writeResp(rw http.ResponseWriter, r *http.Request, resp interface{}) {
-	err := httpx.ResponseFrom(resp).WriteTo(rw, r, handler.resolveTransformer)
+	response := httpx.ResponseFrom(resp)
+
+	if handler.contentNegotiation {
+		if err := negotiateContentType(rw, r, handler.TransformerMgr, response); err != nil {
+			handler.writeErr(rw, r, err)
+			return
+		}
+	}
+
+	err := response.WriteTo(rw, r, handler.resolveTransformer)
 	if err != nil {
 		handler.writeErr(rw, r, err)
 	}
 }
 
+// negotiateContentType picks content type of response by Accept from media types the value could be encoded to,
+// only when content type not declared.
+func negotiateContentType(rw http.ResponseWriter, r *http.Request, transformerMgr transformers.TransformerMgr, response *httpx.Response) error {
+	if response.ContentType != "" || response.Location != nil || response.Value == nil {
+		return nil
+	}
+
+	switch response.Value.(type) {
+	case io.Reader, courier.Result, httpx.Upgrader:
+		return nil
+	}
+
+	offers := transformers.ProducibleMIMEsOf(transformerMgr, typesutil.FromRType(reflect.TypeOf(response.Value)))
+
+	if len(offers) == 0 {
+		return nil
+	}
+
+	if len(offers) > 1 {
+		httpx.AddVary(rw.Header(), httpx.HeaderAccept)
+	}
+
+	accept := r.Header.Get(httpx.HeaderAccept)
+
+	mediaType, ok := httpx.NegotiateContentType(accept, offers)
+	if !ok {
+		return statuserror.Wrap(
+			errors.Errorf("%s not acceptable, could be one of %s", accept, strings.Join(offers, ", ")),
+			http.StatusNotAcceptable,
+			"NotAcceptable",
+		)
+	}
+
+	if mediaType != offers[0] {
+		response.ContentType = mediaType
+	}
+
+	return nil
+}
+
 func (handler *HttpRouteHandler) writeErr(rw http.ResponseWriter, r *http.Request, err error) {
+	writeErr(rw, r, handler.serviceMeta, handler.errorFormat, handler.resolveTransformer, err)
+}
+
+func resolveTransformerBy(transformerMgr transformers.TransformerMgr) func(response *httpx.Response) (httpx.Encode, error) {
+	return func(response *httpx.Response) (httpx.Encode, error) {
+		transformer, err := transformerMgr.NewTransformer(context.Background(), typesutil.FromRType(reflect.TypeOf(response.Value)), transformers.TransformerOption{
+			MIME: response.ContentType,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return transformer.EncodeTo, nil
+	}
+}
+
+type ErrorFormat string
+
+const (
+	// json of statuserror.StatusErr
+	ErrorFormatStatusErr ErrorFormat = "statuserr"
+	// application/problem+json of RFC 9457
+	ErrorFormatProblem ErrorFormat = "problem"
+	// application/problem+json when preferred by Accept, otherwise json of statuserror.StatusErr
+	ErrorFormatNegotiate ErrorFormat = "negotiate"
+)
+
+func (f ErrorFormat) asProblem(rw http.ResponseWriter, r *http.Request) bool {
+	switch f {
+	case ErrorFormatProblem:
+		return true
+	case ErrorFormatNegotiate:
+		httpx.AddVary(rw.Header(), httpx.HeaderAccept)
+		mediaType, _ := httpx.NegotiateContentType(r.Header.Get(httpx.HeaderAccept), []string{httpx.MIME_JSON, httpx.MIME_PROBLEM_JSON})
+		return mediaType == httpx.MIME_PROBLEM_JSON
+	}
+	return false
+}
+
+func writeErr(rw http.ResponseWriter, r *http.Request, serviceMeta *ServiceMeta, errorFormat ErrorFormat, resolveEncodeTo func(response *httpx.Response) (httpx.Encode, error), err error) {
 	resp, ok := err.(*httpx.Response)
 	if !ok {
 		resp = httpx.ResponseFrom(err)
 	}
 
 	if statusErr, ok := statuserror.IsStatusErr(resp.Unwrap()); ok {
-		err := statusErr.AppendSource(handler.serviceMeta.String())
+		err := statusErr.AppendSource(serviceMeta.String())
 
 		if rwe, ok := rw.(ResponseWithError); ok {
 			rwe.WriteError(err)
 		}
 
 		resp.Value = err
+
+		if errorFormat.asProblem(rw, r) {
+			resp.Value = httpx.ProblemDetailsFrom(err)
+			resp.ContentType = httpx.MIME_PROBLEM_JSON
+			resolveEncodeTo = func(response *httpx.Response) (httpx.Encode, error) {
+				return httpx.EncodeProblemDetails, nil
+			}
+		}
 	}
 
-	errForWrite := resp.WriteTo(rw, r, handler.resolveTransformer)
+	errForWrite := resp.WriteTo(rw, r, resolveEncodeTo)
 	if errForWrite != nil {
 		rw.WriteHeader(http.StatusInternalServerError)
 		_, _ = rw.Write([]byte("courier write err failed:" + errForWrite.Error()))
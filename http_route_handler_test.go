@@ -2,16 +2,23 @@ package httptransport_test
 
 import (
 	"context"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"net/http/httputil"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/go-courier/courier"
 	"github.com/go-courier/httptransport"
+	"github.com/go-courier/httptransport/handlers"
+	"github.com/go-courier/httptransport/httpx"
 	"github.com/go-courier/httptransport/testdata/server/cmd/app/routes"
 	"github.com/go-courier/httptransport/testify"
 	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
 )
 
 var rtMgr = httptransport.NewRequestTransformerMgr(nil, nil)
@@ -292,4 +299,614 @@ X-Meta: service-test@1.0.0/GetByID
 {"key":"badRequest","code":400000000,"msg":"invalid parameters","desc":"","canBeTalkError":false,"id":"","sources":["service-test@1.0.0"],"errorFields":[{"field":"id","msg":"string length should be larger than 6, but got invalid value 2","in":"path"}]}
 `))
 	})
+	t.Run("return with validate err as problem details", func(t *testing.T) {
+		rootRouter := courier.NewRouter(httptransport.Group("/root"))
+		rootRouter.Register(courier.NewRouter(routes.DataProvider{}, routes.GetByID{}))
+
+		httpRoute := httptransport.NewHttpRouteMeta(rootRouter.Routes()[0])
+		httpRouterHandler := httptransport.NewHttpRouteHandler(serviceMeta, httpRoute, rtMgr, httptransport.WithErrorFormat(httptransport.ErrorFormatProblem))
+
+		reqData := routes.DataProvider{
+			ID: "10",
+		}
+
+		req, err := rtMgr.NewRequest((routes.GetByID{}).Method(), reqData.Path(), reqData)
+		NewWithT(t).Expect(err).To(BeNil())
+
+		rw := testify.NewMockResponseWriter()
+		httpRouterHandler.ServeHTTP(rw, req)
+
+		NewWithT(t).Expect(string(rw.MustDumpResponse())).To(Equal(`HTTP/0.0 400 Bad Request
+Content-Type: application/problem+json
+X-Meta: service-test@1.0.0/GetByID
+
+{"title":"invalid parameters","status":400,"key":"badRequest","code":400000000,"sources":["service-test@1.0.0"],"errors":[{"field":"id","msg":"string length should be larger than 6, but got invalid value 2","in":"path"}]}
+`))
+	})
+
+	t.Run("negotiate error format", func(t *testing.T) {
+		rootRouter := courier.NewRouter(httptransport.Group("/root"))
+		rootRouter.Register(courier.NewRouter(routes.DataProvider{}, routes.GetByID{}))
+
+		httpRoute := httptransport.NewHttpRouteMeta(rootRouter.Routes()[0])
+		httpRouterHandler := httptransport.NewHttpRouteHandler(serviceMeta, httpRoute, rtMgr, httptransport.WithErrorFormat(httptransport.ErrorFormatNegotiate))
+
+		reqData := routes.DataProvider{
+			ID: "10",
+		}
+
+		cases := map[string]struct {
+			accept      string
+			contentType string
+		}{
+			"without Accept": {"", "application/json; charset=utf-8"},
+			"prefer json":    {"application/json, application/problem+json;q=0.5", "application/json; charset=utf-8"},
+			"prefer problem": {"application/problem+json, application/json;q=0.5", "application/problem+json"},
+			"problem only":   {"application/problem+json", "application/problem+json"},
+			"any":            {"*/*", "application/json; charset=utf-8"},
+		}
+
+		for name, c := range cases {
+			t.Run(name, func(t *testing.T) {
+				req, err := rtMgr.NewRequest((routes.GetByID{}).Method(), reqData.Path(), reqData)
+				NewWithT(t).Expect(err).To(BeNil())
+				req.Header.Set(httpx.HeaderAccept, c.accept)
+
+				rw := testify.NewMockResponseWriter()
+				httpRouterHandler.ServeHTTP(rw, req)
+
+				NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusBadRequest))
+				NewWithT(t).Expect(rw.Header().Get(httpx.HeaderContentType)).To(Equal(c.contentType))
+				NewWithT(t).Expect(rw.Header().Values(httpx.HeaderVary)).To(Equal([]string{httpx.HeaderAccept}))
+			})
+		}
+	})
+
+	t.Run("recover panic", func(t *testing.T) {
+		rootRouter := courier.NewRouter(httptransport.Group("/root"))
+		rootRouter.Register(courier.NewRouter(PanicOperator{}))
+
+		var recovered error
+
+		httpRoute := httptransport.NewHttpRouteMeta(rootRouter.Routes()[0])
+		httpRouterHandler := httptransport.NewHttpRouteHandler(serviceMeta, httpRoute, rtMgr, httptransport.WithPanicRecovery(httptransport.PanicRecovery{
+			OnPanic: func(ctx context.Context, err error, stack []byte) {
+				recovered = err
+			},
+		}))
+
+		req, err := rtMgr.NewRequest((PanicOperator{}).Method(), "/", PanicOperator{})
+		NewWithT(t).Expect(err).To(BeNil())
+
+		rw := testify.NewMockResponseWriter()
+		httpRouterHandler.ServeHTTP(rw, req)
+
+		NewWithT(t).Expect(recovered).NotTo(BeNil())
+		NewWithT(t).Expect(recovered.Error()).To(Equal("boom"))
+
+		NewWithT(t).Expect(string(rw.MustDumpResponse())).To(Equal(`HTTP/0.0 500 Internal Server Error
+Content-Type: application/json; charset=utf-8
+X-Meta: service-test@1.0.0/PanicOperator
+
+{"key":"OperatorPanic","code":500000000,"msg":"internal server error","desc":"operator panicked","canBeTalkError":false,"id":"","sources":["service-test@1.0.0"],"errorFields":null}
+`))
+	})
+	t.Run("default media type for Accept of browser", func(t *testing.T) {
+		rootRouter := courier.NewRouter(httptransport.Group("/root"))
+		rootRouter.Register(courier.NewRouter(routes.Create{}))
+
+		httpRoute := httptransport.NewHttpRouteMeta(rootRouter.Routes()[0])
+		httpRouterHandler := httptransport.NewHttpRouteHandler(serviceMeta, httpRoute, rtMgr)
+
+		reqData := routes.Create{
+			Data: routes.Data{
+				ID:    "123456",
+				Label: "123",
+			},
+		}
+
+		req, err := rtMgr.NewRequest((routes.Create{}).Method(), "/", reqData)
+		NewWithT(t).Expect(err).To(BeNil())
+		req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+
+		rw := testify.NewMockResponseWriter()
+		httpRouterHandler.ServeHTTP(rw, req)
+
+		NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusCreated))
+		NewWithT(t).Expect(rw.Header().Get("Content-Type")).To(HavePrefix("application/json"))
+		NewWithT(t).Expect(rw.Header().Get("Vary")).To(BeEmpty())
+	})
+
+	t.Run("negotiate by Accept", func(t *testing.T) {
+		rootRouter := courier.NewRouter(httptransport.Group("/root"))
+		rootRouter.Register(courier.NewRouter(routes.Create{}))
+
+		httpRoute := httptransport.NewHttpRouteMeta(rootRouter.Routes()[0])
+		httpRouterHandler := httptransport.NewHttpRouteHandler(serviceMeta, httpRoute, rtMgr, httptransport.WithContentNegotiation(true))
+
+		reqData := routes.Create{
+			Data: routes.Data{
+				ID:    "123456",
+				Label: "123",
+			},
+		}
+
+		req, err := rtMgr.NewRequest((routes.Create{}).Method(), "/", reqData)
+		NewWithT(t).Expect(err).To(BeNil())
+		req.Header.Set("Accept", "text/html, application/xml;q=0.9")
+
+		rw := testify.NewMockResponseWriter()
+		httpRouterHandler.ServeHTTP(rw, req)
+
+		NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusCreated))
+		NewWithT(t).Expect(rw.Header().Get("Content-Type")).To(HavePrefix("application/xml"))
+		NewWithT(t).Expect(rw.Header().Get("Vary")).To(Equal("Accept"))
+	})
+
+	t.Run("not acceptable", func(t *testing.T) {
+		rootRouter := courier.NewRouter(httptransport.Group("/root"))
+		rootRouter.Register(courier.NewRouter(routes.Create{}))
+
+		httpRoute := httptransport.NewHttpRouteMeta(rootRouter.Routes()[0])
+		httpRouterHandler := httptransport.NewHttpRouteHandler(serviceMeta, httpRoute, rtMgr, httptransport.WithContentNegotiation(true))
+
+		reqData := routes.Create{
+			Data: routes.Data{
+				ID:    "123456",
+				Label: "123",
+			},
+		}
+
+		req, err := rtMgr.NewRequest((routes.Create{}).Method(), "/", reqData)
+		NewWithT(t).Expect(err).To(BeNil())
+		req.Header.Set("Accept", "text/html")
+
+		rw := testify.NewMockResponseWriter()
+		httpRouterHandler.ServeHTTP(rw, req)
+
+		NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusNotAcceptable))
+		NewWithT(t).Expect(string(rw.MustDumpResponse())).To(ContainSubstring(`"key":"NotAcceptable","code":406000000`))
+	})
+	t.Run("request entity too large", func(t *testing.T) {
+		rootRouter := courier.NewRouter(httptransport.Group("/root"))
+		rootRouter.Register(courier.NewRouter(routes.Create{}))
+
+		httpRoute := httptransport.NewHttpRouteMeta(rootRouter.Routes()[0])
+		httpRouterHandler := httptransport.NewHttpRouteHandler(serviceMeta, httpRoute, rtMgr, httptransport.WithBodyLimits(httptransport.BodyLimits{
+			MaxBodySize: 8,
+		}))
+
+		reqData := routes.Create{
+			Data: routes.Data{
+				ID:    "123456",
+				Label: "123",
+			},
+		}
+
+		t.Run("by Content-Length", func(t *testing.T) {
+			req, err := rtMgr.NewRequest((routes.Create{}).Method(), "/", reqData)
+			NewWithT(t).Expect(err).To(BeNil())
+
+			rw := testify.NewMockResponseWriter()
+			httpRouterHandler.ServeHTTP(rw, req)
+
+			NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusRequestEntityTooLarge))
+			NewWithT(t).Expect(string(rw.MustDumpResponse())).To(ContainSubstring(`"key":"RequestEntityTooLarge","code":413000000`))
+		})
+
+		t.Run("by reading with limits of operator", func(t *testing.T) {
+			rootRouter := courier.NewRouter(httptransport.Group("/root"))
+			rootRouter.Register(courier.NewRouter(UploadText{}))
+
+			httpRoute := httptransport.NewHttpRouteMeta(rootRouter.Routes()[0])
+			httpRouterHandler := httptransport.NewHttpRouteHandler(serviceMeta, httpRoute, rtMgr)
+
+			req, err := rtMgr.NewRequest((UploadText{}).Method(), "/", UploadText{Text: "text longer than limits"})
+			NewWithT(t).Expect(err).To(BeNil())
+			// unknown length like chunked
+			req.ContentLength = -1
+
+			rw := testify.NewMockResponseWriter()
+			httpRouterHandler.ServeHTTP(rw, req)
+
+			NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusRequestEntityTooLarge))
+			NewWithT(t).Expect(string(rw.MustDumpResponse())).To(ContainSubstring(`"key":"RequestEntityTooLarge","code":413000000`))
+		})
+
+		t.Run("overridden by group", func(t *testing.T) {
+			rootRouter := courier.NewRouter(httptransport.Group("/root").WithBodyLimits(httptransport.BodyLimits{
+				MaxBodySize: 1024,
+			}))
+			rootRouter.Register(courier.NewRouter(routes.Create{}))
+
+			httpRoute := httptransport.NewHttpRouteMeta(rootRouter.Routes()[0])
+			httpRouterHandler := httptransport.NewHttpRouteHandler(serviceMeta, httpRoute, rtMgr, httptransport.WithBodyLimits(httptransport.BodyLimits{
+				MaxBodySize: 8,
+			}))
+
+			req, err := rtMgr.NewRequest((routes.Create{}).Method(), "/", reqData)
+			NewWithT(t).Expect(err).To(BeNil())
+
+			rw := testify.NewMockResponseWriter()
+			httpRouterHandler.ServeHTTP(rw, req)
+
+			NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusCreated))
+		})
+	})
 }
+
+func TestHttpRouteHandlerWithDecodeTimeout(t *testing.T) {
+	rootRouter := courier.NewRouter(httptransport.Group("/root"))
+	rootRouter.Register(courier.NewRouter(SlowUploadText{}))
+
+	httpRoute := httptransport.NewHttpRouteMeta(rootRouter.Routes()[0])
+	srv := httptest.NewServer(httptransport.NewHttpRouteHandler(serviceMeta, httpRoute, rtMgr))
+	defer srv.Close()
+
+	t.Run("output longer than decode timeout", func(t *testing.T) {
+		resp, err := http.Post(srv.URL, httpx.MIME_PLAIN, strings.NewReader("text"))
+		NewWithT(t).Expect(err).To(BeNil())
+		defer resp.Body.Close()
+
+		data, _ := io.ReadAll(resp.Body)
+		NewWithT(t).Expect(resp.StatusCode).To(Equal(http.StatusCreated))
+		NewWithT(t).Expect(string(data)).To(Equal("text"))
+	})
+
+	t.Run("slow body", func(t *testing.T) {
+		pr, pw := io.Pipe()
+		defer pw.Close()
+
+		go func() {
+			_, _ = pw.Write([]byte("te"))
+		}()
+
+		resp, err := http.Post(srv.URL, httpx.MIME_PLAIN, pr)
+		NewWithT(t).Expect(err).To(BeNil())
+		defer resp.Body.Close()
+
+		data, _ := io.ReadAll(resp.Body)
+		NewWithT(t).Expect(resp.StatusCode).To(Equal(http.StatusRequestTimeout))
+		NewWithT(t).Expect(string(data)).To(ContainSubstring(`"key":"RequestTimeout"`))
+	})
+}
+
+func TestHttpRouteHandlerWithIdempotency(t *testing.T) {
+	newHandler := func(operators ...courier.Operator) *httptransport.HttpRouteHandler {
+		rootRouter := courier.NewRouter(httptransport.Group("/root"))
+		rootRouter.Register(courier.NewRouter(operators...))
+		return httptransport.NewHttpRouteHandler(serviceMeta, httptransport.NewHttpRouteMeta(rootRouter.Routes()[0]), rtMgr)
+	}
+
+	serve := func(handler http.Handler, name string, idempotencyKey string) *testify.MockResponseWriter {
+		req, err := rtMgr.NewRequest(http.MethodPost, "/", CreateOrder{Name: name})
+		NewWithT(t).Expect(err).To(BeNil())
+		if idempotencyKey != "" {
+			req.Header.Set(httpx.HeaderIdempotencyKey, idempotencyKey)
+		}
+		rw := testify.NewMockResponseWriter()
+		handler.ServeHTTP(rw, req)
+		return rw
+	}
+
+	t.Run("replay", func(t *testing.T) {
+		handler := newHandler(&CreateOrder{})
+
+		rw := serve(handler, "a", "key-1")
+		NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusCreated))
+		created := string(rw.MustDumpResponse())
+
+		t.Run("same key", func(t *testing.T) {
+			rw := serve(handler, "a", "key-1")
+			NewWithT(t).Expect(rw.Header().Get(httpx.HeaderIdempotentReplayed)).To(Equal("true"))
+			NewWithT(t).Expect(strings.Replace(string(rw.MustDumpResponse()), "Idempotent-Replayed: true\n", "", 1)).To(Equal(created))
+		})
+
+		t.Run("same key with headers of current request", func(t *testing.T) {
+			traceHandler := handlers.TraceContextHandler()(handler)
+
+			req, err := rtMgr.NewRequest(http.MethodPost, "/", CreateOrder{Name: "a"})
+			NewWithT(t).Expect(err).To(BeNil())
+			req.Header.Set(httpx.HeaderIdempotencyKey, "key-3")
+			req.Header.Set(httpx.HeaderRequestID, "request-1")
+
+			rw := testify.NewMockResponseWriter()
+			traceHandler.ServeHTTP(rw, req)
+			NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusCreated))
+			NewWithT(t).Expect(rw.Header().Get(httpx.HeaderRequestID)).To(Equal("request-1"))
+
+			req.Header.Set(httpx.HeaderRequestID, "request-2")
+
+			replayed := testify.NewMockResponseWriter()
+			traceHandler.ServeHTTP(replayed, req)
+			NewWithT(t).Expect(replayed.Header().Get(httpx.HeaderIdempotentReplayed)).To(Equal("true"))
+			NewWithT(t).Expect(replayed.Header().Get(httpx.HeaderRequestID)).To(Equal("request-2"))
+			NewWithT(t).Expect(replayed.Header().Get(httpx.HeaderTraceParent)).NotTo(Equal(rw.Header().Get(httpx.HeaderTraceParent)))
+			NewWithT(t).Expect(replayed.String()).To(Equal(rw.String()))
+		})
+
+		t.Run("other key", func(t *testing.T) {
+			rw := serve(handler, "a", "key-2")
+			NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusCreated))
+			NewWithT(t).Expect(rw.Header().Get(httpx.HeaderIdempotentReplayed)).To(Equal(""))
+			NewWithT(t).Expect(string(rw.MustDumpResponse())).NotTo(Equal(created))
+		})
+
+		t.Run("same key of other request", func(t *testing.T) {
+			rw := serve(handler, "b", "key-1")
+			NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusUnprocessableEntity))
+		})
+
+		t.Run("without key", func(t *testing.T) {
+			rw := serve(handler, "a", "")
+			NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusCreated))
+			NewWithT(t).Expect(string(rw.MustDumpResponse())).NotTo(Equal(created))
+		})
+	})
+
+	t.Run("required by group", func(t *testing.T) {
+		rootRouter := courier.NewRouter(httptransport.Group("/root").WithIdempotency(httptransport.Idempotency{Required: true}))
+		rootRouter.Register(courier.NewRouter(routes.Create{}))
+		handler := httptransport.NewHttpRouteHandler(serviceMeta, httptransport.NewHttpRouteMeta(rootRouter.Routes()[0]), rtMgr)
+
+		req, err := rtMgr.NewRequest(http.MethodPost, "/", routes.Create{Data: routes.Data{ID: "123456", Label: "123"}})
+		NewWithT(t).Expect(err).To(BeNil())
+
+		rw := testify.NewMockResponseWriter()
+		handler.ServeHTTP(rw, req)
+
+		NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusBadRequest))
+		NewWithT(t).Expect(string(rw.MustDumpResponse())).To(ContainSubstring(`"key":"IdempotencyKeyRequired"`))
+	})
+
+	t.Run("in flight", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+
+		onCreateOrder = func() {
+			close(started)
+			<-release
+		}
+		defer func() {
+			onCreateOrder = nil
+		}()
+
+		handler := newHandler(&CreateOrder{})
+
+		done := make(chan *testify.MockResponseWriter)
+		go func() {
+			done <- serve(handler, "a", "key-1")
+		}()
+
+		<-started
+
+		rw := serve(handler, "a", "key-1")
+		NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusConflict))
+		NewWithT(t).Expect(string(rw.MustDumpResponse())).To(ContainSubstring(`"key":"IdempotencyKeyInFlight"`))
+
+		close(release)
+		NewWithT(t).Expect((<-done).StatusCode).To(Equal(http.StatusCreated))
+
+		rw = serve(handler, "a", "key-1")
+		NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusCreated))
+		NewWithT(t).Expect(rw.Header().Get(httpx.HeaderIdempotentReplayed)).To(Equal("true"))
+	})
+
+	t.Run("released when failed", func(t *testing.T) {
+		handler := newHandler(&CreateOrder{})
+
+		rw := serve(handler, "fail", "key-1")
+		NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusInternalServerError))
+
+		rw = serve(handler, "fail", "key-1")
+		NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusInternalServerError))
+		NewWithT(t).Expect(rw.Header().Get(httpx.HeaderIdempotentReplayed)).To(Equal(""))
+	})
+}
+
+func TestHttpRouteHandlerWithTracer(t *testing.T) {
+	exporter := httptransport.NewMemorySpanExporter()
+
+	rootRouter := courier.NewRouter(httptransport.Group("/root"))
+	rootRouter.Register(courier.NewRouter(routes.DataProvider{}, routes.GetByID{}))
+
+	httpRoute := httptransport.NewHttpRouteMeta(rootRouter.Routes()[0])
+	handler := httptransport.NewHttpRouteHandler(serviceMeta, httpRoute, rtMgr, httptransport.WithTracer(httptransport.NewTracer(exporter)))
+
+	serve := func(id string) (*testify.MockResponseWriter, httpx.TraceContext) {
+		reqData := struct {
+			routes.DataProvider
+			routes.GetByID
+		}{
+			DataProvider: routes.DataProvider{ID: id},
+		}
+
+		req, err := rtMgr.NewRequest(http.MethodGet, reqData.Path(), reqData)
+		NewWithT(t).Expect(err).To(BeNil())
+		req.Header.Set(httpx.HeaderTraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+		tc := httpx.TraceContextFromRequest(req)
+
+		rw := testify.NewMockResponseWriter()
+		handler.ServeHTTP(rw, req.WithContext(httpx.ContextWithTraceContext(req.Context(), tc)))
+		return rw, tc
+	}
+
+	t.Run("spans of operators", func(t *testing.T) {
+		exporter.Reset()
+
+		rw, tc := serve("123456")
+		NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusOK))
+
+		spans := exporter.Spans()
+		NewWithT(t).Expect(spans).To(HaveLen(3))
+
+		dataProviderSpan, getByIDSpan, routeSpan := spans[0], spans[1], spans[2]
+
+		NewWithT(t).Expect(routeSpan.Name).To(Equal("service-test@1.0.0/GetByID"))
+		NewWithT(t).Expect(routeSpan.Kind).To(Equal(httptransport.SpanKindServer))
+		NewWithT(t).Expect(routeSpan.TraceID).To(Equal(tc.TraceParent.TraceID))
+		NewWithT(t).Expect(routeSpan.SpanID).To(Equal(tc.TraceParent.SpanID))
+		NewWithT(t).Expect(routeSpan.ParentSpanID).To(Equal(tc.ParentSpanID))
+		NewWithT(t).Expect(routeSpan.Attributes).To(HaveKeyWithValue("http.status_code", http.StatusOK))
+		NewWithT(t).Expect(routeSpan.Attributes).To(HaveKeyWithValue("operation_id", "GetByID"))
+		NewWithT(t).Expect(routeSpan.Err).To(BeNil())
+
+		NewWithT(t).Expect(dataProviderSpan.Name).To(Equal("routes.DataProvider"))
+		NewWithT(t).Expect(getByIDSpan.Name).To(Equal("routes.GetByID"))
+
+		for _, span := range []*httptransport.SpanData{dataProviderSpan, getByIDSpan} {
+			NewWithT(t).Expect(span.Kind).To(Equal(httptransport.SpanKindInternal))
+			NewWithT(t).Expect(span.TraceID).To(Equal(routeSpan.TraceID))
+			NewWithT(t).Expect(span.ParentSpanID).To(Equal(routeSpan.SpanID))
+			NewWithT(t).Expect(span.Attributes).To(HaveKey("decode.duration_ms"))
+			NewWithT(t).Expect(span.Attributes).To(HaveKey("output.duration_ms"))
+		}
+	})
+
+	t.Run("error of operator", func(t *testing.T) {
+		exporter.Reset()
+
+		rw, _ := serve("1")
+		NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusBadRequest))
+
+		spans := exporter.Spans()
+		NewWithT(t).Expect(spans).To(HaveLen(2))
+
+		NewWithT(t).Expect(spans[0].Name).To(Equal("routes.DataProvider"))
+		NewWithT(t).Expect(spans[0].Err).NotTo(BeNil())
+		NewWithT(t).Expect(spans[0].Attributes).NotTo(HaveKey("output.duration_ms"))
+
+		NewWithT(t).Expect(spans[1].Err).NotTo(BeNil())
+		NewWithT(t).Expect(spans[1].Attributes).To(HaveKeyWithValue("http.status_code", http.StatusBadRequest))
+	})
+
+	t.Run("context of operator with span of operator", func(t *testing.T) {
+		exporter.Reset()
+
+		tracedRouter := courier.NewRouter(httptransport.Group("/traced"))
+		tracedRouter.Register(courier.NewRouter(TracedOperator{}))
+
+		handler := httptransport.NewHttpRouteHandler(serviceMeta, httptransport.NewHttpRouteMeta(tracedRouter.Routes()[0]), rtMgr, httptransport.WithTracer(httptransport.NewTracer(exporter)))
+
+		req, _ := http.NewRequest(http.MethodGet, "/traced", nil)
+		rw := testify.NewMockResponseWriter()
+		handler.ServeHTTP(rw, req)
+		NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusNoContent))
+
+		spans := exporter.Spans()
+		NewWithT(t).Expect(spans).To(HaveLen(2))
+		NewWithT(t).Expect(spans[0].Name).To(Equal("httptransport_test.TracedOperator"))
+		NewWithT(t).Expect(spanIDOfTracedOperator).To(Equal(spans[0].SpanID))
+	})
+
+	t.Run("spans of operators after operator returned context", func(t *testing.T) {
+		exporter.Reset()
+
+		tracedRouter := courier.NewRouter(httptransport.Group("/traced"))
+		tracedRouter.Register(courier.NewRouter(ContextProvider{}, TracedOperator{}))
+
+		handler := httptransport.NewHttpRouteHandler(serviceMeta, httptransport.NewHttpRouteMeta(tracedRouter.Routes()[0]), rtMgr, httptransport.WithTracer(httptransport.NewTracer(exporter)))
+
+		req, _ := http.NewRequest(http.MethodGet, "/traced", nil)
+		rw := testify.NewMockResponseWriter()
+		handler.ServeHTTP(rw, req)
+		NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusNoContent))
+
+		spans := exporter.Spans()
+		NewWithT(t).Expect(spans).To(HaveLen(3))
+
+		contextProviderSpan, tracedSpan, routeSpan := spans[0], spans[1], spans[2]
+
+		NewWithT(t).Expect(contextProviderSpan.ParentSpanID).To(Equal(routeSpan.SpanID))
+		NewWithT(t).Expect(tracedSpan.ParentSpanID).To(Equal(routeSpan.SpanID))
+		NewWithT(t).Expect(spanIDOfTracedOperator).To(Equal(tracedSpan.SpanID))
+	})
+}
+
+type PanicOperator struct {
+	httpx.MethodGet
+}
+
+func (PanicOperator) Output(ctx context.Context) (interface{}, error) {
+	panic("boom")
+}
+
+type UploadText struct {
+	httpx.MethodPost
+	Text string `in:"body"`
+}
+
+func (UploadText) BodyLimits() httptransport.BodyLimits {
+	return httptransport.BodyLimits{MaxBodySize: 8}
+}
+
+func (req UploadText) Output(ctx context.Context) (interface{}, error) {
+	return req.Text, nil
+}
+
+// span id of trace context in context of Output
+var spanIDOfTracedOperator httpx.SpanID
+
+type contextKeyTraced struct{}
+
+// ContextProvider returns context derived from context of Output, like auth
+type ContextProvider struct{}
+
+func (ContextProvider) Output(ctx context.Context) (interface{}, error) {
+	return context.WithValue(ctx, contextKeyTraced{}, true), nil
+}
+
+type TracedOperator struct {
+	httpx.MethodGet
+}
+
+func (TracedOperator) Output(ctx context.Context) (interface{}, error) {
+	tc, _ := httpx.TraceContextFromContext(ctx)
+	spanIDOfTracedOperator = tc.TraceParent.SpanID
+	return nil, nil
+}
+
+type SlowUploadText struct {
+	httpx.MethodPost
+	Text string `in:"body"`
+}
+
+func (SlowUploadText) BodyLimits() httptransport.BodyLimits {
+	return httptransport.BodyLimits{DecodeTimeout: 100 * time.Millisecond}
+}
+
+func (req SlowUploadText) Output(ctx context.Context) (interface{}, error) {
+	time.Sleep(300 * time.Millisecond)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return req.Text, nil
+}
+
+type CreateOrder struct {
+	httpx.MethodPost
+	Name string `name:"name" in:"query"`
+}
+
+func (CreateOrder) Idempotency() *httptransport.Idempotency {
+	return &httptransport.Idempotency{}
+}
+
+func (req *CreateOrder) Output(ctx context.Context) (interface{}, error) {
+	if req.Name == "fail" {
+		return nil, errors.New("failed")
+	}
+	if onCreateOrder != nil {
+		onCreateOrder()
+	}
+	return map[string]interface{}{
+		"id":   atomic.AddInt32(&orderID, 1),
+		"name": req.Name,
+	}, nil
+}
+
+var (
+	orderID       int32
+	onCreateOrder func()
+)
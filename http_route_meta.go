@@ -24,6 +24,12 @@ type BasePathDescriber interface {
 	BasePath() string
 }
 
+// MiddlewaresDescriber operator (or MetaOperator of Group or BasePath) with middlewares,
+// which wrap http.Handler of each route containing it
+type MiddlewaresDescriber interface {
+	Middlewares() []HttpMiddleware
+}
+
 var pkgPathHttpx = reflect.TypeOf(httpx.MethodGet{}).PkgPath()
 
 func NewOperatorFactoryWithRouteMeta(op courier.Operator, last bool) *OperatorFactoryWithRouteMeta {
@@ -162,6 +168,36 @@ func (route *HttpRouteMeta) Log() {
 	courierPrintln("\t%s", route.OperatorNames())
 }
 
+type HttpRouteInfo struct {
+	Method     string   `json:"method"`
+	Path       string   `json:"path"`
+	Operators  []string `json:"operators"`
+	Summary    string   `json:"summary,omitempty"`
+	Deprecated bool     `json:"deprecated,omitempty"`
+}
+
+// Info returns route info without colors
+func (route *HttpRouteMeta) Info() *HttpRouteInfo {
+	last := route.OperatorFactoryWithRouteMetas[len(route.OperatorFactoryWithRouteMetas)-1]
+
+	info := &HttpRouteInfo{
+		Method:     route.Method(),
+		Path:       reHttpRouterPath.ReplaceAllString(route.Path(), "/{$1}"),
+		Operators:  make([]string, 0),
+		Summary:    last.Summary,
+		Deprecated: last.Deprecated,
+	}
+
+	for _, opFactory := range route.OperatorFactoryWithRouteMetas {
+		if opFactory.NoOutput {
+			continue
+		}
+		info.Operators = append(info.Operators, opFactory.String())
+	}
+
+	return info
+}
+
 var reHttpRouterPath = regexp.MustCompile("/:([^/]+)")
 
 func methodColor(method string) func(f string, args ...interface{}) string {
@@ -189,6 +225,19 @@ func (route *HttpRouteMeta) Method() string {
 	return method
 }
 
+// Middlewares returns middlewares of operators in route, outer first
+func (route *HttpRouteMeta) Middlewares() []HttpMiddleware {
+	middlewares := make([]HttpMiddleware, 0)
+
+	for _, m := range route.OperatorFactoryWithRouteMetas {
+		if middlewaresDescriber, ok := m.Operator.(MiddlewaresDescriber); ok {
+			middlewares = append(middlewares, middlewaresDescriber.Middlewares()...)
+		}
+	}
+
+	return middlewares
+}
+
 func (route *HttpRouteMeta) Path() string {
 	basePath := "/"
 	p := ""
@@ -218,8 +267,41 @@ func Group(path string) *MetaOperator {
 
 type MetaOperator struct {
 	courier.EmptyOperator
-	path     string
-	basePath string
+	path        string
+	basePath    string
+	middlewares []HttpMiddleware
+	bodyLimits  BodyLimits
+	idempotency *Idempotency
+}
+
+// WithMiddlewares attaches middlewares to all routes registered under
+func (g *MetaOperator) WithMiddlewares(middlewares ...HttpMiddleware) *MetaOperator {
+	g.middlewares = append(g.middlewares, middlewares...)
+	return g
+}
+
+func (g *MetaOperator) Middlewares() []HttpMiddleware {
+	return g.middlewares
+}
+
+// WithBodyLimits overrides BodyLimits of all routes registered under
+func (g *MetaOperator) WithBodyLimits(bodyLimits BodyLimits) *MetaOperator {
+	g.bodyLimits = bodyLimits
+	return g
+}
+
+func (g *MetaOperator) BodyLimits() BodyLimits {
+	return g.bodyLimits
+}
+
+// WithIdempotency enables Idempotency of all routes registered under
+func (g *MetaOperator) WithIdempotency(idempotency Idempotency) *MetaOperator {
+	g.idempotency = &idempotency
+	return g
+}
+
+func (g *MetaOperator) Idempotency() *Idempotency {
+	return g.idempotency
 }
 
 func (g *MetaOperator) Path() string {
@@ -0,0 +1,309 @@
+package httptransport
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-courier/httptransport/httpx"
+	"github.com/go-courier/logr"
+	contextx "github.com/go-courier/x/context"
+	"github.com/pkg/errors"
+)
+
+// SpanKind values same as OpenTelemetry
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// Tracer starts spans of route and its operators in HttpRouteHandler,
+// could be implemented by adapter of OpenTelemetry or others
+type Tracer interface {
+	// Start starts span as child of span in ctx,
+	// returns context with span for downstream.
+	Start(ctx context.Context, name string, kind SpanKind) (context.Context, Span)
+}
+
+type Span interface {
+	SetAttributes(keysAndValues ...interface{})
+	RecordError(err error)
+	End()
+}
+
+// SpanData of ended span
+type SpanData struct {
+	TraceID      httpx.TraceID
+	SpanID       httpx.SpanID
+	ParentSpanID httpx.SpanID
+	Name         string
+	Kind         SpanKind
+	StartTime    time.Time
+	EndTime      time.Time
+	Attributes   map[string]interface{}
+	Err          error
+}
+
+func (s *SpanData) Duration() time.Duration {
+	return s.EndTime.Sub(s.StartTime)
+}
+
+// SpanExporter exports ended spans
+type SpanExporter interface {
+	ExportSpans(ctx context.Context, spans []*SpanData) error
+}
+
+// NewTracer creates Tracer exporting spans when ended.
+// trace continued from httpx.TraceContext in context, like parsed by handlers.TraceContextHandler,
+// and spans of unsampled traces dropped.
+func NewTracer(exporter SpanExporter) Tracer {
+	return &tracer{exporter: exporter}
+}
+
+type tracer struct {
+	exporter SpanExporter
+}
+
+// shutdowner for tracer and exporters buffering spans, like OTLPHTTPExporter
+type shutdowner interface {
+	Shutdown(ctx context.Context) error
+}
+
+// Shutdown shuts down exporter implemented Shutdown, for exporting buffered spans
+func (t *tracer) Shutdown(ctx context.Context) error {
+	if s, ok := t.exporter.(shutdowner); ok {
+		return s.Shutdown(ctx)
+	}
+	return nil
+}
+
+type contextKeySpan struct{}
+
+func (t *tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, Span) {
+	data := &SpanData{
+		Name:       name,
+		Kind:       kind,
+		StartTime:  time.Now(),
+		Attributes: map[string]interface{}{},
+	}
+
+	tc, ok := httpx.TraceContextFromContext(ctx)
+
+	if parent, isSpan := ctx.Value(contextKeySpan{}).(*span); isSpan {
+		data.TraceID = parent.data.TraceID
+		data.ParentSpanID = parent.data.SpanID
+		data.SpanID = httpx.NewSpanID()
+	} else if ok {
+		// span id of current service allocated when trace context parsed
+		data.TraceID = tc.TraceParent.TraceID
+		data.ParentSpanID = tc.ParentSpanID
+		data.SpanID = tc.TraceParent.SpanID
+	} else {
+		tc.TraceParent.Flags = 0x01
+		data.TraceID = httpx.NewTraceID()
+		data.SpanID = httpx.NewSpanID()
+	}
+
+	if !tc.TraceParent.Sampled() {
+		return ctx, noopSpan{}
+	}
+
+	s := &span{ctx: ctx, exporter: t.exporter, data: data}
+
+	// requests to downstream as children of span
+	tc.TraceParent.TraceID = data.TraceID
+	tc.TraceParent.SpanID = data.SpanID
+
+	ctx = contextx.WithValue(ctx, contextKeySpan{}, s)
+	ctx = httpx.ContextWithTraceContext(ctx, tc)
+
+	return ctx, s
+}
+
+type span struct {
+	ctx      context.Context
+	exporter SpanExporter
+
+	mu   sync.Mutex
+	data *SpanData
+	once sync.Once
+}
+
+func (s *span) SetAttributes(keysAndValues ...interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		if key, ok := keysAndValues[i].(string); ok {
+			s.data.Attributes[key] = keysAndValues[i+1]
+		}
+	}
+}
+
+func (s *span) RecordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Err = err
+}
+
+// End exports span, only first call works
+func (s *span) End() {
+	s.once.Do(func() {
+		s.mu.Lock()
+		s.data.EndTime = time.Now()
+		s.mu.Unlock()
+
+		if err := s.exporter.ExportSpans(s.ctx, []*SpanData{s.data}); err != nil {
+			logr.FromContext(s.ctx).Warn(errors.Wrap(err, "export span failed"))
+		}
+	})
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(keysAndValues ...interface{}) {}
+func (noopSpan) RecordError(err error)                      {}
+func (noopSpan) End()                                       {}
+
+func NewMemorySpanExporter() *MemorySpanExporter {
+	return &MemorySpanExporter{}
+}
+
+// MemorySpanExporter keeps spans in memory for testing
+type MemorySpanExporter struct {
+	mu    sync.Mutex
+	spans []*SpanData
+}
+
+func (e *MemorySpanExporter) ExportSpans(ctx context.Context, spans []*SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = append(e.spans, spans...)
+
+	return nil
+}
+
+// Spans returns exported spans in order of ending
+func (e *MemorySpanExporter) Spans() []*SpanData {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return append([]*SpanData{}, e.spans...)
+}
+
+func (e *MemorySpanExporter) Reset() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = nil
+}
+
+// routeTrace holds span of route and span of current operator,
+// records status and error of response as ResponseWriter
+type routeTrace struct {
+	http.ResponseWriter
+	// context with span of route
+	ctx          context.Context
+	tracer       Tracer
+	span         Span
+	operatorSpan Span
+	statusCode   int
+}
+
+func startRouteTrace(ctx context.Context, tracer Tracer, rw http.ResponseWriter, name string) (context.Context, *routeTrace) {
+	ctx, span := tracer.Start(ctx, name, SpanKindServer)
+
+	return ctx, &routeTrace{
+		ResponseWriter: rw,
+		ctx:            ctx,
+		tracer:         tracer,
+		span:           span,
+	}
+}
+
+// startOperator starts span of operator as child of span of route, and ends span of previous operator,
+// returns context with span of operator for the operator
+func (t *routeTrace) startOperator(ctx context.Context, name string) context.Context {
+	if t == nil {
+		return ctx
+	}
+	t.endOperator()
+	ctx, t.operatorSpan = t.tracer.Start(ctx, name, SpanKindInternal)
+	return ctx
+}
+
+// withRouteSpan replaces span of operator and httpx.TraceContext in context returned by operator with ones of route,
+// so that spans of next operators and requests to downstream are children of span of route
+func (t *routeTrace) withRouteSpan(ctx context.Context) context.Context {
+	if t == nil {
+		return ctx
+	}
+	if s, ok := t.ctx.Value(contextKeySpan{}).(*span); ok {
+		ctx = contextx.WithValue(ctx, contextKeySpan{}, s)
+	}
+	if tc, ok := httpx.TraceContextFromContext(t.ctx); ok {
+		ctx = httpx.ContextWithTraceContext(ctx, tc)
+	}
+	return ctx
+}
+
+func (t *routeTrace) setOperatorAttributes(keysAndValues ...interface{}) {
+	if t == nil || t.operatorSpan == nil {
+		return
+	}
+	t.operatorSpan.SetAttributes(keysAndValues...)
+}
+
+func (t *routeTrace) endOperator() {
+	if t.operatorSpan != nil {
+		t.operatorSpan.End()
+		t.operatorSpan = nil
+	}
+}
+
+func (t *routeTrace) end() {
+	t.endOperator()
+	if t.statusCode != 0 {
+		t.span.SetAttributes("http.status_code", t.statusCode)
+	}
+	t.span.End()
+}
+
+func (t *routeTrace) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
+
+// WriteError records error on spans of route and current operator
+func (t *routeTrace) WriteError(err error) {
+	t.span.RecordError(err)
+	if t.operatorSpan != nil {
+		t.operatorSpan.RecordError(err)
+	}
+	if rwe, ok := t.ResponseWriter.(ResponseWithError); ok {
+		rwe.WriteError(err)
+	}
+}
+
+func (t *routeTrace) WriteHeader(statusCode int) {
+	if t.statusCode == 0 {
+		t.statusCode = statusCode
+	}
+	t.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (t *routeTrace) Write(p []byte) (int, error) {
+	if t.statusCode == 0 {
+		t.statusCode = http.StatusOK
+	}
+	return t.ResponseWriter.Write(p)
+}
+
+func millisecondsSince(t time.Time) float64 {
+	return float64(time.Since(t)) / float64(time.Millisecond)
+}
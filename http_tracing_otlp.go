@@ -0,0 +1,319 @@
+package httptransport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-courier/httptransport/httpx"
+	"github.com/go-courier/logr"
+	"github.com/pkg/errors"
+)
+
+// NewOTLPHTTPExporter creates exporter to endpoint of OTLP/HTTP collector, like http://127.0.0.1:4318/v1/traces
+func NewOTLPHTTPExporter(endpoint string, serviceName string) *OTLPHTTPExporter {
+	return &OTLPHTTPExporter{
+		Endpoint:    endpoint,
+		ServiceName: serviceName,
+	}
+}
+
+// OTLPHTTPExporter exports spans in JSON encoding of OTLP over HTTP.
+// spans buffered and exported in batch by BatchSize or FlushInterval in one background goroutine,
+// Shutdown should be called before exit for exporting buffered spans.
+type OTLPHTTPExporter struct {
+	Endpoint    string
+	ServiceName string
+	// extra headers, like for authorization of collector
+	Header http.Header
+	// default http.Client with 10s timeout
+	Client *http.Client
+	// default 512
+	BatchSize int
+	// default 5s
+	FlushInterval time.Duration
+	// timeout of each export in background, default 10s
+	ExportTimeout time.Duration
+
+	mu      sync.Mutex
+	spans   []*SpanData
+	started bool
+	flush   chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func (e *OTLPHTTPExporter) SetDefaults() {
+	if e.Client == nil {
+		e.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if e.BatchSize == 0 {
+		e.BatchSize = 512
+	}
+	if e.FlushInterval == 0 {
+		e.FlushInterval = 5 * time.Second
+	}
+	if e.ExportTimeout == 0 {
+		e.ExportTimeout = 10 * time.Second
+	}
+}
+
+// ExportSpans buffers spans, exports in background when batch full
+func (e *OTLPHTTPExporter) ExportSpans(ctx context.Context, spans []*SpanData) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.started {
+		e.SetDefaults()
+		e.started = true
+		e.flush = make(chan struct{}, 1)
+		e.stop = make(chan struct{})
+		e.done = make(chan struct{})
+		go e.loop(logr.FromContext(ctx))
+	}
+
+	e.spans = append(e.spans, spans...)
+
+	if len(e.spans) >= e.BatchSize {
+		// skipped when loop notified already
+		select {
+		case e.flush <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+func (e *OTLPHTTPExporter) loop(l logr.Logger) {
+	defer close(e.done)
+
+	ticker := time.NewTicker(e.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-e.flush:
+		case <-e.stop:
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), e.ExportTimeout)
+		if err := e.Flush(ctx); err != nil {
+			l.Warn(errors.Wrap(err, "export spans failed"))
+		}
+		cancel()
+	}
+}
+
+// Flush exports buffered spans in batches of BatchSize
+func (e *OTLPHTTPExporter) Flush(ctx context.Context) error {
+	e.mu.Lock()
+	e.SetDefaults()
+	spans := e.spans
+	e.spans = nil
+	e.mu.Unlock()
+
+	for len(spans) > 0 {
+		n := e.BatchSize
+		if n > len(spans) {
+			n = len(spans)
+		}
+
+		if err := e.export(ctx, spans[:n]); err != nil {
+			return err
+		}
+
+		spans = spans[n:]
+	}
+
+	return nil
+}
+
+// Shutdown stops exporting in background, waits for export in progress, and flushes buffered spans
+func (e *OTLPHTTPExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	started, stop, done := e.started, e.stop, e.done
+	e.started = false
+	e.mu.Unlock()
+
+	if started {
+		close(stop)
+
+		select {
+		case <-done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return e.Flush(ctx)
+}
+
+func (e *OTLPHTTPExporter) export(ctx context.Context, spans []*SpanData) error {
+	data, err := json.Marshal(e.toTracesData(spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	for key, values := range e.Header {
+		req.Header[key] = values
+	}
+	req.Header.Set(httpx.HeaderContentType, httpx.MIME_JSON)
+
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return errors.Errorf("export spans to %s failed: %s %s", e.Endpoint, resp.Status, body)
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	return nil
+}
+
+// OTLP JSON of https://github.com/open-telemetry/opentelemetry-proto/blob/main/opentelemetry/proto/trace/v1/trace.proto
+
+type otlpTracesData struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              SpanKind       `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            *otlpStatus    `json:"status,omitempty"`
+}
+
+type otlpStatus struct {
+	// 2 for error
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func (e *OTLPHTTPExporter) toTracesData(spans []*SpanData) *otlpTracesData {
+	scopeSpans := otlpScopeSpans{
+		Scope: otlpScope{Name: "github.com/go-courier/httptransport"},
+		Spans: make([]otlpSpan, len(spans)),
+	}
+
+	for i, s := range spans {
+		span := otlpSpan{
+			TraceID:           s.TraceID.String(),
+			SpanID:            s.SpanID.String(),
+			Name:              s.Name,
+			Kind:              s.Kind,
+			StartTimeUnixNano: strconv.FormatInt(s.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.EndTime.UnixNano(), 10),
+			Attributes:        toOTLPKeyValues(s.Attributes),
+		}
+
+		if s.ParentSpanID.IsValid() {
+			span.ParentSpanID = s.ParentSpanID.String()
+		}
+
+		if s.Err != nil {
+			span.Status = &otlpStatus{Code: 2, Message: s.Err.Error()}
+		}
+
+		scopeSpans.Spans[i] = span
+	}
+
+	return &otlpTracesData{
+		ResourceSpans: []otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: toOTLPKeyValues(map[string]interface{}{"service.name": e.ServiceName}),
+			},
+			ScopeSpans: []otlpScopeSpans{scopeSpans},
+		}},
+	}
+}
+
+func toOTLPKeyValues(attributes map[string]interface{}) []otlpKeyValue {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	keyValues := make([]otlpKeyValue, len(keys))
+
+	for i, key := range keys {
+		keyValues[i] = otlpKeyValue{Key: key, Value: toOTLPAnyValue(attributes[key])}
+	}
+
+	return keyValues
+}
+
+func toOTLPAnyValue(v interface{}) otlpAnyValue {
+	switch x := v.(type) {
+	case bool:
+		return otlpAnyValue{BoolValue: &x}
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		s := fmt.Sprintf("%d", x)
+		return otlpAnyValue{IntValue: &s}
+	case float32:
+		f := float64(x)
+		return otlpAnyValue{DoubleValue: &f}
+	case float64:
+		return otlpAnyValue{DoubleValue: &x}
+	case string:
+		return otlpAnyValue{StringValue: &x}
+	default:
+		s := fmt.Sprint(x)
+		return otlpAnyValue{StringValue: &s}
+	}
+}
@@ -0,0 +1,134 @@
+package httptransport_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-courier/httptransport"
+	"github.com/go-courier/httptransport/httpx"
+	. "github.com/onsi/gomega"
+)
+
+func TestOTLPHTTPExporter(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []map[string]interface{}
+	)
+
+	collector := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		NewWithT(t).Expect(req.URL.Path).To(Equal("/v1/traces"))
+		NewWithT(t).Expect(req.Header.Get("Content-Type")).To(Equal("application/json"))
+		NewWithT(t).Expect(req.Header.Get("Authorization")).To(Equal("Bearer token"))
+
+		data := map[string]interface{}{}
+		NewWithT(t).Expect(json.NewDecoder(req.Body).Decode(&data)).To(BeNil())
+
+		mu.Lock()
+		received = append(received, data)
+		mu.Unlock()
+	}))
+	defer collector.Close()
+
+	exporter := httptransport.NewOTLPHTTPExporter(collector.URL+"/v1/traces", "service-test")
+	exporter.Header = http.Header{"Authorization": {"Bearer token"}}
+
+	tracer := httptransport.NewTracer(exporter)
+
+	ctx, span := tracer.Start(context.Background(), "route", httptransport.SpanKindServer)
+	span.SetAttributes("http.status_code", 500, "http.route", "/root/:id")
+
+	_, child := tracer.Start(ctx, "operator", httptransport.SpanKindInternal)
+	child.SetAttributes("output.duration_ms", 1.5)
+	child.RecordError(errors.New("failed"))
+	child.End()
+
+	span.End()
+
+	tc, _ := httpx.TraceContextFromContext(ctx)
+
+	NewWithT(t).Expect(exporter.Shutdown(context.Background())).To(BeNil())
+
+	NewWithT(t).Expect(received).To(HaveLen(1))
+
+	resourceSpans := received[0]["resourceSpans"].([]interface{})[0].(map[string]interface{})
+	NewWithT(t).Expect(resourceSpans["resource"]).To(Equal(map[string]interface{}{
+		"attributes": []interface{}{
+			map[string]interface{}{"key": "service.name", "value": map[string]interface{}{"stringValue": "service-test"}},
+		},
+	}))
+
+	spans := resourceSpans["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})
+	NewWithT(t).Expect(spans).To(HaveLen(2))
+
+	childData, spanData := spans[0].(map[string]interface{}), spans[1].(map[string]interface{})
+
+	NewWithT(t).Expect(spanData["traceId"]).To(Equal(tc.TraceParent.TraceID.String()))
+	NewWithT(t).Expect(spanData["spanId"]).To(Equal(tc.TraceParent.SpanID.String()))
+	NewWithT(t).Expect(spanData).NotTo(HaveKey("parentSpanId"))
+	NewWithT(t).Expect(spanData["name"]).To(Equal("route"))
+	NewWithT(t).Expect(spanData["kind"]).To(Equal(float64(httptransport.SpanKindServer)))
+	NewWithT(t).Expect(spanData["attributes"]).To(Equal([]interface{}{
+		map[string]interface{}{"key": "http.route", "value": map[string]interface{}{"stringValue": "/root/:id"}},
+		map[string]interface{}{"key": "http.status_code", "value": map[string]interface{}{"intValue": "500"}},
+	}))
+
+	NewWithT(t).Expect(childData["traceId"]).To(Equal(tc.TraceParent.TraceID.String()))
+	NewWithT(t).Expect(childData["parentSpanId"]).To(Equal(tc.TraceParent.SpanID.String()))
+	NewWithT(t).Expect(childData["kind"]).To(Equal(float64(httptransport.SpanKindInternal)))
+	NewWithT(t).Expect(childData["status"]).To(Equal(map[string]interface{}{"code": float64(2), "message": "failed"}))
+	NewWithT(t).Expect(childData["attributes"]).To(Equal([]interface{}{
+		map[string]interface{}{"key": "output.duration_ms", "value": map[string]interface{}{"doubleValue": 1.5}},
+	}))
+	NewWithT(t).Expect(childData["startTimeUnixNano"]).To(MatchRegexp(`^\d+$`))
+}
+
+func TestOTLPHTTPExporterWithFullBatch(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		batches []int
+	)
+
+	collector := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		data := map[string]interface{}{}
+		NewWithT(t).Expect(json.NewDecoder(req.Body).Decode(&data)).To(BeNil())
+
+		spans := data["resourceSpans"].([]interface{})[0].(map[string]interface{})["scopeSpans"].([]interface{})[0].(map[string]interface{})["spans"].([]interface{})
+
+		mu.Lock()
+		batches = append(batches, len(spans))
+		mu.Unlock()
+	}))
+	defer collector.Close()
+
+	exporter := httptransport.NewOTLPHTTPExporter(collector.URL+"/v1/traces", "service-test")
+	exporter.BatchSize = 2
+	exporter.FlushInterval = time.Hour
+
+	tracer := httptransport.NewTracer(exporter)
+
+	endSpans := func(n int) {
+		for i := 0; i < n; i++ {
+			_, span := tracer.Start(context.Background(), "route", httptransport.SpanKindServer)
+			span.End()
+		}
+	}
+
+	endSpans(2)
+
+	NewWithT(t).Eventually(func() []int {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]int{}, batches...)
+	}).Should(Equal([]int{2}))
+
+	endSpans(1)
+
+	NewWithT(t).Expect(exporter.Shutdown(context.Background())).To(BeNil())
+	NewWithT(t).Expect(batches).To(Equal([]int{2, 1}))
+}
@@ -2,23 +2,30 @@ package httptransport
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
 	"sort"
+	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/go-courier/logr"
+	"github.com/go-courier/statuserror"
 
 	"github.com/go-courier/courier"
 	"github.com/go-courier/httptransport/handlers"
 	"github.com/go-courier/httptransport/transformers"
 	"github.com/go-courier/httptransport/validator"
 	"github.com/julienschmidt/httprouter"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 func MiddlewareChain(mw ...HttpMiddleware) HttpMiddleware {
@@ -42,12 +49,16 @@ func NewHttpTransport(serverModifiers ...ServerModifier) *HttpTransport {
 type HttpTransport struct {
 	ServiceMeta
 
+	// port to listen on, default 80 when no Listeners provided
 	Port int
+	// caller-provided listeners (unix socket, port 0 or extra addresses), served with same router and tls of CertFile and KeyFile.
+	// listeners closed when serving failed or stopped.
+	Listeners []net.Listener
 
 	// for modifying http.Server
 	ServerModifiers []ServerModifier
 
-	// Middlewares
+	// Middlewares, default handlers.TraceContextHandler and handlers.LogHandler (and handlers.MetricsHandler when MetricsPath set)
 	// can use https://github.com/gorilla/handlers
 	Middlewares []HttpMiddleware
 
@@ -58,12 +69,86 @@ type HttpTransport struct {
 
 	CertFile string
 	KeyFile  string
+	// interval for checking CertFile and KeyFile changed and reloading, 0 means never reload
+	CertReloadInterval time.Duration
+	// CA file for verifying client certificates, enable mutual TLS with tls.RequireAndVerifyClientCert by default
+	ClientCAFile string
+	// client auth mode, overwrite the default of ClientCAFile
+	ClientAuth tls.ClientAuthType
+
+	// serve cleartext HTTP/2 (h2c) with prior knowledge and Upgrade, for tls terminated by sidecar
+	H2C bool
+
+	// path of liveness probe, like /healthz, disabled when empty
+	LivenessPath string
+	// path of readiness probe, like /readyz, disabled when empty.
+	// readiness fails when not serving or shutting down.
+	ReadinessPath string
+	// checks for liveness probe
+	LivenessChecks HealthChecks
+	// checks for readiness probe
+	ReadinessChecks HealthChecks
+	// path of listing registered routes as json, like /debug/routes, disabled when empty
+	RoutesPath string
+	// path of metrics in text format of Prometheus, like /metrics, disabled when empty.
+	// serves MetricsRegistry, and handlers.MetricsHandler added to default Middlewares when enabled.
+	MetricsPath string
+	// registry of metrics, default handlers.DefaultMetricsRegistry
+	MetricsRegistry *handlers.MetricsRegistry
+
+	// recovery of operator panics
+	PanicRecovery PanicRecovery
+
+	// limits of request body, could be overridden by operators implementing BodyLimitsDescriber
+	BodyLimits BodyLimits
+
+	// store of responses for operators with IdempotencyDescriber, default in-memory store
+	IdempotencyStore IdempotencyStore
+
+	// tracer for spans of routes and operators, like NewTracer(NewOTLPHTTPExporter(endpoint)), disabled when nil
+	Tracer Tracer
+
+	// format of error responses, default ErrorFormatStatusErr
+	ErrorFormat ErrorFormat
+	// negotiate media type of responses by Accept, like application/xml for struct values.
+	// disabled by default, responses in default media type of value, like application/json for struct values.
+	ContentNegotiation bool
+
+	// format of route registration and listening output, default LogFormatPretty
+	LogFormat LogFormat
+
+	// max duration for draining in-flight requests when shutdown, default 10s
+	ShutdownTimeout time.Duration
+	// hooks called before http.Server.Shutdown, like marking readiness failed and waiting for load balancer
+	PreShutdownHooks []ShutdownHook
+	// hooks called after all in-flight requests drained
+	PostShutdownHooks []ShutdownHook
 
 	httpRouter *httprouter.Router
+	// Middlewares wrapped httpRouter for requests without matched route, like 404, 405 and OPTIONS
+	unmatchedHandler http.Handler
+
+	mu    sync.RWMutex
+	addrs []net.Addr
+
+	serving atomic.Bool
 }
 
 type ServerModifier func(server *http.Server) error
 
+type ShutdownHook func(ctx context.Context) error
+
+type LogFormat string
+
+const (
+	// colored text to stdout
+	LogFormatPretty LogFormat = "pretty"
+	// structured fields through logr
+	LogFormatStructured LogFormat = "structured"
+	// silence
+	LogFormatNone LogFormat = "none"
+)
+
 func (t *HttpTransport) SetDefaults() {
 	t.ServiceMeta.SetDefaults()
 
@@ -75,38 +160,143 @@ func (t *HttpTransport) SetDefaults() {
 		t.TransformerMgr = transformers.TransformerMgrDefault
 	}
 
+	if t.MetricsRegistry == nil {
+		t.MetricsRegistry = handlers.DefaultMetricsRegistry
+	}
+
 	if t.Middlewares == nil {
-		t.Middlewares = []HttpMiddleware{handlers.LogHandler()}
+		t.Middlewares = []HttpMiddleware{handlers.TraceContextHandler(), handlers.LogHandler()}
+
+		if t.MetricsPath != "" {
+			t.Middlewares = append(t.Middlewares, handlers.MetricsHandler(handlers.WithMetricsRegistry(t.MetricsRegistry)))
+		}
 	}
 
-	if t.Port == 0 {
+	if t.Port == 0 && len(t.Listeners) == 0 {
 		t.Port = 80
 	}
+
+	if t.LogFormat == "" {
+		t.LogFormat = LogFormatPretty
+	}
+
+	if t.IdempotencyStore == nil {
+		t.IdempotencyStore = NewMemoryIdempotencyStore()
+	}
+
+	if t.ErrorFormat == "" {
+		t.ErrorFormat = ErrorFormatStatusErr
+	}
+
+	if t.ShutdownTimeout == 0 {
+		t.ShutdownTimeout = 10 * time.Second
+	}
 }
 
+// ServeHTTP routes request, handle of each route wrapped with Middlewares
 func (t *HttpTransport) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	t.httpRouter.ServeHTTP(w, req)
+	if handle, params, _ := t.httpRouter.Lookup(req.Method, req.URL.Path); handle != nil {
+		handle(w, req, params)
+		return
+	}
+	t.unmatchedHandler.ServeHTTP(w, req)
+}
+
+var routeMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+}
+
+// AllowedMethods returns methods of registered routes matched path,
+// used by handlers.CORSHandler when handlers.CORSOption.AllowedMethods not set
+func (t *HttpTransport) AllowedMethods(path string) []string {
+	if t.httpRouter == nil {
+		return nil
+	}
+
+	methods := make([]string, 0)
+
+	for _, method := range routeMethods {
+		if h, _, _ := t.httpRouter.Lookup(method, path); h != nil {
+			methods = append(methods, method)
+		}
+	}
+
+	return methods
 }
 
 func courierPrintln(format string, args ...interface{}) {
 	fmt.Printf(`[Courier] `+format+"\n", args...)
 }
 
+func (t *HttpTransport) logListen(l logr.Logger, addr net.Addr) {
+	switch t.LogFormat {
+	case LogFormatNone:
+	case LogFormatStructured:
+		l.WithValues("service", t.ServiceMeta.String(), "addr", addr.String()).Info("listen")
+	default:
+		courierPrintln("%s listen on %s", t.ServiceMeta, addr)
+	}
+}
+
+func (t *HttpTransport) logRoute(l logr.Logger, route *HttpRouteMeta) {
+	switch t.LogFormat {
+	case LogFormatNone:
+	case LogFormatStructured:
+		info := route.Info()
+		l.WithValues(
+			"method", info.Method,
+			"path", info.Path,
+			"operators", info.Operators,
+			"deprecated", info.Deprecated,
+		).Info("route registered")
+	default:
+		route.Log()
+	}
+}
+
 func (t *HttpTransport) Serve(router *courier.Router) error {
 	return t.ServeContext(context.Background(), router)
 }
 
+// ServeContext serves until ctx done, SIGINT or SIGTERM received, or server failed.
+// errors of listening (like port in use) will be returned directly.
 func (t *HttpTransport) ServeContext(ctx context.Context, router *courier.Router) error {
 	t.SetDefaults()
 
 	l := logr.FromContext(ctx)
 
-	t.httpRouter = t.convertRouterToHttpRouter(router)
+	t.httpRouter = t.convertRouterToHttpRouter(l, router)
+	t.unmatchedHandler = t.withMiddlewares("", t.httpRouter)
 
 	srv := &http.Server{}
 
-	srv.Addr = fmt.Sprintf(":%d", t.Port)
-	srv.Handler = MiddlewareChain(t.Middlewares...)(t)
+	if t.Port != 0 {
+		srv.Addr = fmt.Sprintf(":%d", t.Port)
+	}
+	srv.Handler = t
+
+	if t.H2C {
+		srv.Handler = h2c.NewHandler(srv.Handler, &http2.Server{})
+	}
+
+	tlsEnabled := t.CertFile != "" && t.KeyFile != ""
+
+	if tlsEnabled {
+		tlsCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		tlsConfig, err := t.tlsConfig(tlsCtx)
+		if err != nil {
+			closeListeners(t.Listeners)
+			return err
+		}
+		srv.TLSConfig = tlsConfig
+	}
 
 	for i := range t.ServerModifiers {
 		if err := t.ServerModifiers[i](srv); err != nil {
@@ -114,36 +304,122 @@ func (t *HttpTransport) ServeContext(ctx context.Context, router *courier.Router
 		}
 	}
 
-	go func() {
-		courierPrintln("%s listen on %s", t.ServiceMeta, srv.Addr)
+	listeners := append([]net.Listener{}, t.Listeners...)
 
-		if t.CertFile != "" && t.KeyFile != "" {
-			if err := srv.ListenAndServeTLS(t.CertFile, t.KeyFile); err != nil {
-				l.Error(err)
-			}
-			return
+	if srv.Addr != "" {
+		ln, err := net.Listen("tcp", srv.Addr)
+		if err != nil {
+			closeListeners(t.Listeners)
+			return err
 		}
+		listeners = append(listeners, ln)
+	}
 
-		if err := srv.ListenAndServe(); err != nil {
-			l.Error(err)
-		}
-	}()
+	addrs := make([]net.Addr, len(listeners))
+	for i := range listeners {
+		addrs[i] = listeners[i].Addr()
+		t.logListen(l, addrs[i])
+	}
+	t.setAddrs(addrs)
+	defer t.setAddrs(nil)
+
+	serveErrCh := make(chan error, len(listeners))
+
+	for i := range listeners {
+		ln := listeners[i]
+
+		go func() {
+			if tlsEnabled {
+				// keypair provided by tls.Config.GetCertificate
+				serveErrCh <- srv.ServeTLS(ln, "", "")
+				return
+			}
+
+			serveErrCh <- srv.Serve(ln)
+		}()
+	}
+
+	t.serving.Store(true)
+	defer t.serving.Store(false)
 
 	stopCh := make(chan os.Signal, 1)
 	signal.Notify(stopCh, os.Interrupt, syscall.SIGTERM)
-	<-stopCh
+	defer signal.Stop(stopCh)
 
-	timeout := 10 * time.Second
+	select {
+	case err := <-serveErrCh:
+		if err == http.ErrServerClosed {
+			return nil
+		}
+		// other listeners should be closed too
+		_ = t.shutdown(logr.WithLogger(context.Background(), l), srv)
+		return err
+	case <-stopCh:
+	case <-ctx.Done():
+	}
+
+	return t.shutdown(logr.WithLogger(context.Background(), l), srv)
+}
+
+func closeListeners(listeners []net.Listener) {
+	for i := range listeners {
+		_ = listeners[i].Close()
+	}
+}
+
+// Addrs returns addresses of listeners which serving
+func (t *HttpTransport) Addrs() []net.Addr {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.addrs
+}
+
+func (t *HttpTransport) setAddrs(addrs []net.Addr) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.addrs = addrs
+}
+
+func (t *HttpTransport) shutdown(ctx context.Context, srv *http.Server) error {
+	l := logr.FromContext(ctx)
+
+	// mark readiness failed first
+	t.serving.Store(false)
+
+	for i := range t.PreShutdownHooks {
+		if err := t.PreShutdownHooks[i](ctx); err != nil {
+			l.Error(errors.Wrap(err, "pre shutdown hook failed"))
+		}
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	l.Info("shutdowning in %s", t.ShutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(ctx, t.ShutdownTimeout)
 	defer cancel()
 
-	l.Info("shutdowning in %s", timeout)
+	err := srv.Shutdown(shutdownCtx)
+
+	postShutdownHooks := t.PostShutdownHooks
+
+	// spans buffered by exporter of tracer exported after in-flight requests drained
+	if s, ok := t.Tracer.(shutdowner); ok {
+		postShutdownHooks = append(postShutdownHooks[:len(postShutdownHooks):len(postShutdownHooks)], func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, t.ShutdownTimeout)
+			defer cancel()
+			return s.Shutdown(ctx)
+		})
+	}
+
+	for i := range postShutdownHooks {
+		if err := postShutdownHooks[i](ctx); err != nil {
+			l.Error(errors.Wrap(err, "post shutdown hook failed"))
+		}
+	}
 
-	return srv.Shutdown(ctx)
+	return err
 }
 
-func (t *HttpTransport) convertRouterToHttpRouter(router *courier.Router) *httprouter.Router {
+func (t *HttpTransport) convertRouterToHttpRouter(l logr.Logger, router *courier.Router) *httprouter.Router {
 	routes := router.Routes()
 
 	if len(routes) == 0 {
@@ -161,21 +437,86 @@ func (t *HttpTransport) convertRouterToHttpRouter(router *courier.Router) *httpr
 		return routeMetas[i].Key() < routeMetas[j].Key()
 	})
 
+	registered := map[string]bool{}
+	handlersForGet := map[string]http.Handler{}
+
 	for i := range routeMetas {
 		httpRoute := routeMetas[i]
-		httpRoute.Log()
+		t.logRoute(l, httpRoute)
 
 		if err := tryCatch(func() {
-			httpRouter.HandlerFunc(
+			var handler http.Handler = NewHttpRouteHandler(
+				&t.ServiceMeta,
+				httpRoute,
+				NewRequestTransformerMgr(t.TransformerMgr, t.ValidatorMgr),
+				WithPanicRecovery(t.PanicRecovery),
+				WithBodyLimits(t.BodyLimits),
+				WithErrorFormat(t.ErrorFormat),
+				WithContentNegotiation(t.ContentNegotiation),
+				WithIdempotencyStore(t.IdempotencyStore),
+				WithTracer(t.Tracer),
+			)
+
+			if middlewares := httpRoute.Middlewares(); len(middlewares) > 0 {
+				handler = MiddlewareChain(middlewares...)(handler)
+			}
+
+			handler = t.withMiddlewares(httpRoute.OperatorFactoryWithRouteMetas[len(httpRoute.OperatorFactoryWithRouteMetas)-1].ID, handler)
+
+			httpRouter.Handler(
 				httpRoute.Method(),
 				httpRoute.Path(),
-				NewHttpRouteHandler(&t.ServiceMeta, httpRoute, NewRequestTransformerMgr(t.TransformerMgr, t.ValidatorMgr)).ServeHTTP,
+				handler,
 			)
+
+			registered[httpRoute.Method()+" "+httpRoute.Path()] = true
+
+			if httpRoute.Method() == http.MethodGet {
+				handlersForGet[httpRoute.Path()] = handler
+			}
 		}); err != nil {
 			panic(errors.Errorf("register http route `%s` failed: %s", httpRoute, err))
 		}
 	}
 
+	// answer HEAD by GET routes, body will be discarded by http.Server
+	for path, handler := range handlersForGet {
+		if registered[http.MethodHead+" "+path] {
+			continue
+		}
+		if err := tryCatch(func() {
+			httpRouter.Handler(http.MethodHead, path, handler)
+		}); err != nil {
+			panic(errors.Errorf("register http route `HEAD %s` failed: %s", path, err))
+		}
+	}
+
+	t.registerBuiltinRoutes(httpRouter, routeMetas)
+
+	resolveEncodeTo := resolveTransformerBy(t.TransformerMgr)
+
+	// OPTIONS answered with Allow header by registered routes
+	httpRouter.GlobalOPTIONS = http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.WriteHeader(http.StatusNoContent)
+	})
+
+	// Allow header set by httprouter before
+	httpRouter.MethodNotAllowed = http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		writeErr(rw, r, &t.ServiceMeta, t.ErrorFormat, resolveEncodeTo, statuserror.Wrap(
+			errors.Errorf("method %s not allowed for %s", r.Method, r.URL.Path),
+			http.StatusMethodNotAllowed,
+			"MethodNotAllowed",
+		))
+	})
+
+	httpRouter.NotFound = http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		writeErr(rw, r, &t.ServiceMeta, t.ErrorFormat, resolveEncodeTo, statuserror.Wrap(
+			errors.Errorf("no route for %s %s", r.Method, r.URL.Path),
+			http.StatusNotFound,
+			"NotFound",
+		))
+	})
+
 	return httpRouter
 }
 
@@ -189,3 +530,27 @@ func tryCatch(fn func()) (err error) {
 	fn()
 	return nil
 }
+
+// withMiddlewares wraps handler of route with Middlewares,
+// operation id of route, writer of error responses and allowed methods set in context for middlewares,
+// like handlers.LimitByOperationID, handlers.RateLimitHandler and handlers.CORSHandler
+func (t *HttpTransport) withMiddlewares(operationID string, handler http.Handler) http.Handler {
+	handler = MiddlewareChain(t.Middlewares...)(handler)
+
+	resolveEncodeTo := resolveTransformerBy(t.TransformerMgr)
+
+	writeError := func(rw http.ResponseWriter, r *http.Request, err error) {
+		writeErr(rw, r, &t.ServiceMeta, t.ErrorFormat, resolveEncodeTo, err)
+	}
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		ctx := handlers.ContextWithErrorWriter(req.Context(), writeError)
+		ctx = handlers.ContextWithAllowedMethods(ctx, t.AllowedMethods)
+
+		if operationID != "" {
+			ctx = handlers.ContextWithOperationID(ctx, operationID)
+		}
+
+		handler.ServeHTTP(rw, req.WithContext(ctx))
+	})
+}
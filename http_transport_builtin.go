@@ -0,0 +1,116 @@
+package httptransport
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/go-courier/httptransport/httpx"
+	"github.com/julienschmidt/httprouter"
+	"github.com/pkg/errors"
+)
+
+type HealthCheck func(ctx context.Context) error
+
+// HealthChecks named health checks
+type HealthChecks map[string]HealthCheck
+
+type HealthStatus struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+func (checks HealthChecks) Check(ctx context.Context) (*HealthStatus, bool) {
+	names := make([]string, 0, len(checks))
+	for name := range checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	status := &HealthStatus{Status: "ok"}
+	healthy := true
+
+	for _, name := range names {
+		if status.Checks == nil {
+			status.Checks = map[string]string{}
+		}
+
+		if err := checks[name](ctx); err != nil {
+			healthy = false
+			status.Checks[name] = err.Error()
+			continue
+		}
+
+		status.Checks[name] = "ok"
+	}
+
+	if !healthy {
+		status.Status = "failed"
+	}
+
+	return status, healthy
+}
+
+func (t *HttpTransport) registerBuiltinRoutes(httpRouter *httprouter.Router, routeMetas []*HttpRouteMeta) {
+	register := func(path string, handler http.HandlerFunc) {
+		if err := tryCatch(func() {
+			httpRouter.Handler(http.MethodGet, path, t.withMiddlewares("", handler))
+		}); err != nil {
+			panic(errors.Errorf("register builtin route `%s` failed: %s", path, err))
+		}
+	}
+
+	if t.LivenessPath != "" {
+		register(t.LivenessPath, func(rw http.ResponseWriter, r *http.Request) {
+			status, healthy := t.LivenessChecks.Check(r.Context())
+			writeHealthStatus(rw, status, healthy)
+		})
+	}
+
+	if t.ReadinessPath != "" {
+		register(t.ReadinessPath, func(rw http.ResponseWriter, r *http.Request) {
+			status, healthy := t.ReadinessChecks.Check(r.Context())
+
+			if !t.serving.Load() {
+				healthy = false
+				status.Status = "failed"
+				if status.Checks == nil {
+					status.Checks = map[string]string{}
+				}
+				status.Checks["serving"] = "not serving or shutting down"
+			}
+
+			writeHealthStatus(rw, status, healthy)
+		})
+	}
+
+	if t.RoutesPath != "" {
+		routeInfos := make([]*HttpRouteInfo, len(routeMetas))
+		for i := range routeMetas {
+			routeInfos[i] = routeMetas[i].Info()
+		}
+
+		register(t.RoutesPath, func(rw http.ResponseWriter, r *http.Request) {
+			writeJSON(rw, http.StatusOK, routeInfos)
+		})
+	}
+
+	if t.MetricsPath != "" {
+		register(t.MetricsPath, t.MetricsRegistry.ServeHTTP)
+	}
+}
+
+func writeHealthStatus(rw http.ResponseWriter, status *HealthStatus, healthy bool) {
+	if healthy {
+		writeJSON(rw, http.StatusOK, status)
+		return
+	}
+	writeJSON(rw, http.StatusServiceUnavailable, status)
+}
+
+func writeJSON(rw http.ResponseWriter, statusCode int, v interface{}) {
+	rw.Header().Set(httpx.HeaderContentType, httpx.MIME_JSON+"; charset=utf-8")
+	rw.WriteHeader(statusCode)
+	_ = json.NewEncoder(rw).Encode(v)
+}
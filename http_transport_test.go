@@ -1,20 +1,33 @@
 package httptransport_test
 
 import (
+	"bufio"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptest"
 	"net/http/httputil"
 	"os"
+	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/go-courier/courier"
 	"github.com/go-courier/httptransport"
 	"github.com/go-courier/httptransport/client"
+	"github.com/go-courier/httptransport/handlers"
+	"github.com/go-courier/httptransport/httpx"
 	"github.com/go-courier/httptransport/testdata/server/cmd/app/routes"
+	"github.com/go-courier/httptransport/testify"
+	"github.com/go-courier/logr"
+	"github.com/go-courier/statuserror"
 	. "github.com/onsi/gomega"
 )
 
@@ -25,8 +38,12 @@ func BenchmarkHttpTransport(b *testing.B) {
 	})
 	ht.SetDefaults()
 	ht.Port = 8080
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	go func() {
-		_ = ht.Serve(routes.RootRouter)
+		_ = ht.ServeContext(ctx, routes.RootRouter)
 	}()
 
 	b.Run("request", func(b *testing.B) {
@@ -34,9 +51,6 @@ func BenchmarkHttpTransport(b *testing.B) {
 			_, _ = http.Get("http://127.0.0.1:8080/demo/restful/123456")
 		}
 	})
-
-	p, _ := os.FindProcess(os.Getpid())
-	_ = p.Signal(os.Interrupt)
 }
 
 func TestHttpTransport(t *testing.T) {
@@ -46,8 +60,12 @@ func TestHttpTransport(t *testing.T) {
 	})
 	ht.SetDefaults()
 	ht.Port = 8080
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	go func() {
-		_ = ht.Serve(routes.RootRouter)
+		_ = ht.ServeContext(ctx, routes.RootRouter)
 	}()
 
 	time.Sleep(1 * time.Second)
@@ -79,9 +97,834 @@ func TestHttpTransport(t *testing.T) {
 		NewWithT(t).Expect(err).To(BeNil())
 		fmt.Println(string(data))
 	})
+}
 
-	p, _ := os.FindProcess(os.Getpid())
-	_ = p.Signal(os.Interrupt)
+func TestHttpTransportServeContext(t *testing.T) {
+	t.Run("stop when context canceled", func(t *testing.T) {
+		ht := httptransport.NewHttpTransport()
+		ht.Port = freePort(t)
+
+		hooks := make([]string, 0)
+
+		ht.PreShutdownHooks = []httptransport.ShutdownHook{func(ctx context.Context) error {
+			hooks = append(hooks, "pre")
+			return nil
+		}}
+		ht.PostShutdownHooks = []httptransport.ShutdownHook{func(ctx context.Context) error {
+			hooks = append(hooks, "post")
+			return nil
+		}}
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- ht.ServeContext(ctx, routes.RootRouter)
+		}()
+
+		time.Sleep(200 * time.Millisecond)
+
+		resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/demo/restful/123456", ht.Port))
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		_ = resp.Body.Close()
+
+		cancel()
+
+		select {
+		case err := <-errCh:
+			NewWithT(t).Expect(err).To(BeNil())
+		case <-time.After(5 * time.Second):
+			t.Fatal("serve not stopped after context canceled")
+		}
+
+		NewWithT(t).Expect(hooks).To(Equal([]string{"pre", "post"}))
+	})
+
+	t.Run("export buffered spans of tracer", func(t *testing.T) {
+		var exported int32
+
+		collector := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			atomic.AddInt32(&exported, 1)
+		}))
+		defer collector.Close()
+
+		exporter := httptransport.NewOTLPHTTPExporter(collector.URL+"/v1/traces", "service-test")
+		exporter.FlushInterval = time.Hour
+
+		ht := httptransport.NewHttpTransport()
+		ht.Port = freePort(t)
+		ht.Tracer = httptransport.NewTracer(exporter)
+
+		ctx, cancel := context.WithCancel(context.Background())
+
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- ht.ServeContext(ctx, routes.RootRouter)
+		}()
+
+		time.Sleep(200 * time.Millisecond)
+
+		resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/demo/restful/123456", ht.Port))
+		NewWithT(t).Expect(err).To(BeNil())
+		_ = resp.Body.Close()
+
+		NewWithT(t).Expect(atomic.LoadInt32(&exported)).To(Equal(int32(0)))
+
+		cancel()
+
+		select {
+		case err := <-errCh:
+			NewWithT(t).Expect(err).To(BeNil())
+		case <-time.After(5 * time.Second):
+			t.Fatal("serve not stopped after context canceled")
+		}
+
+		NewWithT(t).Expect(atomic.LoadInt32(&exported)).To(Equal(int32(1)))
+	})
+
+	t.Run("return err when port in use", func(t *testing.T) {
+		ln, err := net.Listen("tcp", ":0")
+		NewWithT(t).Expect(err).To(BeNil())
+		defer ln.Close()
+
+		callerListener, err := net.Listen("tcp", "127.0.0.1:0")
+		NewWithT(t).Expect(err).To(BeNil())
+
+		ht := httptransport.NewHttpTransport()
+		ht.Port = ln.Addr().(*net.TCPAddr).Port
+		ht.Listeners = []net.Listener{callerListener}
+
+		err = ht.ServeContext(context.Background(), routes.RootRouter)
+		NewWithT(t).Expect(err).NotTo(BeNil())
+
+		// closed with serving failed
+		_, err = callerListener.Accept()
+		NewWithT(t).Expect(err).NotTo(BeNil())
+	})
+}
+
+func TestHttpTransportWithListeners(t *testing.T) {
+	tcpListener, err := net.Listen("tcp", "127.0.0.1:0")
+	NewWithT(t).Expect(err).To(BeNil())
+
+	sock := filepath.Join(t.TempDir(), "courier.sock")
+
+	unixListener, err := net.Listen("unix", sock)
+	NewWithT(t).Expect(err).To(BeNil())
+
+	ht := httptransport.NewHttpTransport()
+	ht.Listeners = []net.Listener{tcpListener, unixListener}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- ht.ServeContext(ctx, routes.RootRouter)
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+
+	NewWithT(t).Expect(ht.Addrs()).To(HaveLen(2))
+
+	t.Run("tcp", func(t *testing.T) {
+		resp, err := http.Get(fmt.Sprintf("http://%s/demo/restful/123456", ht.Addrs()[0]))
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		_ = resp.Body.Close()
+	})
+
+	t.Run("unix", func(t *testing.T) {
+		c := &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+					return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+				},
+			},
+		}
+
+		resp, err := c.Get("http://courier/demo/restful/123456")
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		_ = resp.Body.Close()
+	})
+
+	cancel()
+
+	NewWithT(t).Expect(<-errCh).To(BeNil())
+	NewWithT(t).Expect(ht.Addrs()).To(BeEmpty())
+}
+
+func TestHttpTransportWithH2C(t *testing.T) {
+	ht := httptransport.NewHttpTransport()
+	ht.Port = freePort(t)
+	ht.H2C = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		_ = ht.ServeContext(ctx, routes.RootRouter)
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+
+	c := &client.Client{
+		Host: "127.0.0.1",
+		Port: uint16(ht.Port),
+		H2C:  true,
+	}
+	c.SetDefaults()
+
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d/demo/restful/123456", ht.Port), nil)
+	NewWithT(t).Expect(err).To(BeNil())
+
+	dials := int32(0)
+	dialer := &net.Dialer{}
+
+	clientCtx := client.ContextWithDefaultHttpTransport(context.Background(), &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			atomic.AddInt32(&dials, 1)
+			return dialer.DialContext(ctx, network, addr)
+		},
+	})
+
+	// copied client shares connections too
+	copied := *c
+
+	for _, c := range []*client.Client{c, c, &copied} {
+		result := c.Do(clientCtx, req).(*client.Result)
+
+		data := map[string]interface{}{}
+		_, err = result.Into(&data)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(result.Response.ProtoMajor).To(Equal(2))
+		NewWithT(t).Expect(data["id"]).To(Equal("123456"))
+	}
+
+	// connection reused
+	NewWithT(t).Expect(atomic.LoadInt32(&dials)).To(Equal(int32(1)))
+}
+
+func TestHttpTransportWithTraceContext(t *testing.T) {
+	ht := httptransport.NewHttpTransport()
+	ht.Port = freePort(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		_ = ht.ServeContext(ctx, routes.RootRouter)
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+
+	c := &client.Client{
+		Host: "127.0.0.1",
+		Port: uint16(ht.Port),
+	}
+	c.SetDefaults()
+
+	t.Run("started", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d/demo/restful/123456", ht.Port), nil)
+
+		result := c.Do(context.Background(), req).(*client.Result)
+		_, err := result.Into(nil)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(result.Response.Header.Get(httpx.HeaderRequestID)).NotTo(BeEmpty())
+
+		_, err = httpx.ParseTraceParent(result.Response.Header.Get(httpx.HeaderTraceParent))
+		NewWithT(t).Expect(err).To(BeNil())
+	})
+
+	t.Run("propagated by client", func(t *testing.T) {
+		upstream, _ := http.NewRequest(http.MethodGet, "/", nil)
+		upstream.Header.Set(httpx.HeaderRequestID, "request-1")
+		upstream.Header.Set(httpx.HeaderTraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+		tc := httpx.TraceContextFromRequest(upstream)
+
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d/demo/restful/123456", ht.Port), nil)
+
+		result := c.Do(httpx.ContextWithTraceContext(context.Background(), tc), req).(*client.Result)
+		_, err := result.Into(nil)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(result.Response.Header.Get(httpx.HeaderRequestID)).To(Equal("request-1"))
+
+		tp, err := httpx.ParseTraceParent(result.Response.Header.Get(httpx.HeaderTraceParent))
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(tp.TraceID).To(Equal(tc.TraceParent.TraceID))
+		NewWithT(t).Expect(tp.SpanID).NotTo(Equal(tc.TraceParent.SpanID))
+	})
+}
+
+func TestHttpTransportBuiltinRoutes(t *testing.T) {
+	ht := httptransport.NewHttpTransport()
+	ht.Port = freePort(t)
+	ht.LivenessPath = "/healthz"
+	ht.ReadinessPath = "/readyz"
+	ht.RoutesPath = "/debug/routes"
+	ht.MetricsPath = "/metrics"
+	ht.MetricsRegistry = handlers.NewMetricsRegistry()
+
+	dbReady := false
+
+	ht.ReadinessChecks = httptransport.HealthChecks{
+		"db": func(ctx context.Context) error {
+			if !dbReady {
+				return errors.New("db not ready")
+			}
+			return nil
+		},
+	}
+
+	get := func(path string) (int, string) {
+		resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d%s", ht.Port, path))
+		if err != nil {
+			return 0, err.Error()
+		}
+		defer resp.Body.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, string(data)
+	}
+
+	readinessWhenShutdown := 0
+
+	ht.PreShutdownHooks = []httptransport.ShutdownHook{func(ctx context.Context) error {
+		readinessWhenShutdown, _ = get("/readyz")
+		return nil
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- ht.ServeContext(ctx, routes.RootRouter)
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+
+	t.Run("liveness", func(t *testing.T) {
+		statusCode, body := get("/healthz")
+		NewWithT(t).Expect(statusCode).To(Equal(http.StatusOK))
+		NewWithT(t).Expect(body).To(Equal(`{"status":"ok"}` + "\n"))
+	})
+
+	t.Run("readiness", func(t *testing.T) {
+		statusCode, body := get("/readyz")
+		NewWithT(t).Expect(statusCode).To(Equal(http.StatusServiceUnavailable))
+		NewWithT(t).Expect(body).To(Equal(`{"status":"failed","checks":{"db":"db not ready"}}` + "\n"))
+
+		dbReady = true
+
+		statusCode, body = get("/readyz")
+		NewWithT(t).Expect(statusCode).To(Equal(http.StatusOK))
+		NewWithT(t).Expect(body).To(Equal(`{"status":"ok","checks":{"db":"ok"}}` + "\n"))
+	})
+
+	t.Run("routes", func(t *testing.T) {
+		statusCode, body := get("/debug/routes")
+		NewWithT(t).Expect(statusCode).To(Equal(http.StatusOK))
+		NewWithT(t).Expect(body).To(ContainSubstring(`{"method":"GET","path":"/demo/restful/{id}","operators":["routes.DataProvider","routes.GetByID"]}`))
+	})
+
+	t.Run("metrics", func(t *testing.T) {
+		statusCode, _ := get("/demo/restful/123456")
+		NewWithT(t).Expect(statusCode).To(Equal(http.StatusOK))
+
+		req, _ := http.NewRequest("PURGE", fmt.Sprintf("http://127.0.0.1:%d/demo/restful/123456", ht.Port), nil)
+		resp, err := http.DefaultClient.Do(req)
+		NewWithT(t).Expect(err).To(BeNil())
+		_ = resp.Body.Close()
+
+		statusCode, body := get("/metrics")
+		NewWithT(t).Expect(statusCode).To(Equal(http.StatusOK))
+		NewWithT(t).Expect(body).To(ContainSubstring("# TYPE http_server_requests_total counter\n"))
+		NewWithT(t).Expect(body).To(ContainSubstring(`http_server_requests_total{operation_id="GetByID",method="GET",status="200"} 1`))
+		NewWithT(t).Expect(body).To(ContainSubstring(`http_server_request_duration_seconds_count{operation_id="GetByID",method="GET",status="200"} 1`))
+		NewWithT(t).Expect(body).To(ContainSubstring(`http_server_requests_in_flight{operation_id="GetByID",method="GET"} 0`))
+		NewWithT(t).Expect(body).To(ContainSubstring(`http_server_requests_total{operation_id="",method="OTHER",status="405"} 1`))
+	})
+
+	cancel()
+
+	NewWithT(t).Expect(<-errCh).To(BeNil())
+	NewWithT(t).Expect(readinessWhenShutdown).To(Equal(http.StatusServiceUnavailable))
+}
+
+func TestHttpTransportWithStructuredLog(t *testing.T) {
+	ht := httptransport.NewHttpTransport()
+	ht.Port = freePort(t)
+	ht.LogFormat = httptransport.LogFormatStructured
+
+	logger := testify.NewMockLogger()
+
+	ctx, cancel := context.WithCancel(logr.WithLogger(context.Background(), logger))
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- ht.ServeContext(ctx, routes.RootRouter)
+	}()
+
+	NewWithT(t).Eventually(ht.Addrs).ShouldNot(BeEmpty())
+	cancel()
+	NewWithT(t).Expect(<-errCh).To(BeNil())
+
+	NewWithT(t).Expect(logger.Entries()).To(ContainElements(
+		testify.LogEntry{
+			Level: logr.InfoLevel,
+			Msg:   "route registered",
+			Fields: map[string]interface{}{
+				"method":     "GET",
+				"path":       "/demo/restful/{id}",
+				"operators":  []string{"routes.DataProvider", "routes.GetByID"},
+				"deprecated": false,
+			},
+		},
+		testify.LogEntry{
+			Level: logr.InfoLevel,
+			Msg:   "listen",
+			Fields: map[string]interface{}{
+				"service": ht.ServiceMeta.String(),
+				"addr":    fmt.Sprintf("[::]:%d", ht.Port),
+			},
+		},
+	))
+}
+
+func TestHttpTransportWithRouteMiddlewares(t *testing.T) {
+	withHeader := func(key string, value string) httptransport.HttpMiddleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+				rw.Header().Add(key, value)
+				next.ServeHTTP(rw, req)
+			})
+		}
+	}
+
+	router := courier.NewRouter(httptransport.BasePath("/demo").WithMiddlewares(withHeader("X-Chain", "base")))
+
+	group := courier.NewRouter(httptransport.Group("/group").WithMiddlewares(withHeader("X-Chain", "group")))
+	group.Register(courier.NewRouter(routes.DataProvider{}, routes.GetByID{}))
+
+	router.Register(group)
+	router.Register(courier.NewRouter(routes.Redirect{}))
+
+	ht := httptransport.NewHttpTransport()
+	ht.Port = freePort(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		_ = ht.ServeContext(ctx, router)
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+
+	c := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	t.Run("group", func(t *testing.T) {
+		resp, err := c.Get(fmt.Sprintf("http://127.0.0.1:%d/demo/group/123456", ht.Port))
+		NewWithT(t).Expect(err).To(BeNil())
+		_ = resp.Body.Close()
+
+		NewWithT(t).Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		NewWithT(t).Expect(resp.Header.Values("X-Chain")).To(Equal([]string{"base", "group"}))
+	})
+
+	t.Run("outside group", func(t *testing.T) {
+		resp, err := c.Get(fmt.Sprintf("http://127.0.0.1:%d/demo", ht.Port))
+		NewWithT(t).Expect(err).To(BeNil())
+		_ = resp.Body.Close()
+
+		NewWithT(t).Expect(resp.StatusCode).To(Equal(http.StatusFound))
+		NewWithT(t).Expect(resp.Header.Values("X-Chain")).To(Equal([]string{"base"}))
+	})
+}
+
+func TestHttpTransportWithRateLimit(t *testing.T) {
+	router := courier.NewRouter(httptransport.BasePath("/demo").WithMiddlewares(handlers.RateLimitHandler(handlers.RateLimitOption{
+		Rate: 0.1,
+		Key:  handlers.LimitByOperationID(),
+	})))
+	router.Register(courier.NewRouter(routes.DataProvider{}, routes.GetByID{}))
+	router.Register(courier.NewRouter(routes.Redirect{}))
+
+	ht := httptransport.NewHttpTransport()
+	ht.Port = freePort(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		_ = ht.ServeContext(ctx, router)
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+
+	c := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	get := func(path string) (*http.Response, string) {
+		resp, err := c.Get(fmt.Sprintf("http://127.0.0.1:%d%s", ht.Port, path))
+		NewWithT(t).Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+		return resp, string(data)
+	}
+
+	resp, _ := get("/demo/123456")
+	NewWithT(t).Expect(resp.StatusCode).To(Equal(http.StatusOK))
+	NewWithT(t).Expect(resp.Header.Get("RateLimit-Remaining")).To(Equal("0"))
+
+	resp, body := get("/demo/123456")
+	NewWithT(t).Expect(resp.StatusCode).To(Equal(http.StatusTooManyRequests))
+	NewWithT(t).Expect(resp.Header.Get("Retry-After")).To(Equal("10"))
+	NewWithT(t).Expect(body).To(ContainSubstring(`"key":"TooManyRequests","code":429000000`))
+
+	// limited by operation
+	resp, _ = get("/demo")
+	NewWithT(t).Expect(resp.StatusCode).To(Equal(http.StatusFound))
+}
+
+func TestHttpTransportWithTopLevelRateLimit(t *testing.T) {
+	ht := httptransport.NewHttpTransport()
+	ht.Name = "service-test"
+	ht.Port = freePort(t)
+	ht.ErrorFormat = httptransport.ErrorFormatProblem
+	ht.SetDefaults()
+	ht.Middlewares = append(ht.Middlewares, handlers.RateLimitHandler(handlers.RateLimitOption{
+		Rate: 0.1,
+		Key:  handlers.LimitByOperationID(),
+	}))
+
+	router := courier.NewRouter(httptransport.BasePath("/demo"))
+	router.Register(courier.NewRouter(routes.DataProvider{}, routes.GetByID{}))
+	router.Register(courier.NewRouter(routes.Redirect{}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		_ = ht.ServeContext(ctx, router)
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+
+	c := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	get := func(path string) (*http.Response, string) {
+		resp, err := c.Get(fmt.Sprintf("http://127.0.0.1:%d%s", ht.Port, path))
+		NewWithT(t).Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+		return resp, string(data)
+	}
+
+	resp, _ := get("/demo/123456")
+	NewWithT(t).Expect(resp.StatusCode).To(Equal(http.StatusOK))
+
+	// same operation of other path params
+	resp, body := get("/demo/654321")
+	NewWithT(t).Expect(resp.StatusCode).To(Equal(http.StatusTooManyRequests))
+	NewWithT(t).Expect(resp.Header.Get("Content-Type")).To(Equal("application/problem+json"))
+	NewWithT(t).Expect(body).To(ContainSubstring(`"key":"TooManyRequests"`))
+	NewWithT(t).Expect(body).To(ContainSubstring(`"sources":["service-test`))
+
+	// limited by operation
+	resp, _ = get("/demo")
+	NewWithT(t).Expect(resp.StatusCode).To(Equal(http.StatusFound))
+}
+
+func TestHttpTransportWithCORS(t *testing.T) {
+	ht := httptransport.NewHttpTransport()
+	ht.Port = freePort(t)
+	ht.SetDefaults()
+	ht.Middlewares = append(ht.Middlewares, handlers.CORSHandler(handlers.CORSOption{
+		Policies: []handlers.CORSPolicy{
+			{
+				AllowedOrigins: []string{"*"},
+			},
+		},
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		_ = ht.ServeContext(ctx, routes.RootRouter)
+	}()
+
+	NewWithT(t).Eventually(ht.Addrs).ShouldNot(BeEmpty())
+
+	preflight := func(path string) *http.Response {
+		req, _ := http.NewRequest(http.MethodOptions, fmt.Sprintf("http://127.0.0.1:%d%s", ht.Port, path), nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Access-Control-Request-Method", http.MethodPut)
+		resp, err := http.DefaultClient.Do(req)
+		NewWithT(t).Expect(err).To(BeNil())
+		_ = resp.Body.Close()
+		return resp
+	}
+
+	t.Run("preflight by methods of routes", func(t *testing.T) {
+		resp := preflight("/demo/restful/1")
+		NewWithT(t).Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+		NewWithT(t).Expect(resp.Header.Get("Access-Control-Allow-Origin")).To(Equal("*"))
+		NewWithT(t).Expect(resp.Header.Get("Access-Control-Allow-Methods")).To(Equal("GET, HEAD, PUT, DELETE"))
+	})
+
+	t.Run("preflight of unknown path", func(t *testing.T) {
+		resp := preflight("/unknown")
+		NewWithT(t).Expect(resp.Header.Get("Access-Control-Allow-Methods")).To(BeEmpty())
+	})
+}
+
+func TestHttpTransportFallbacks(t *testing.T) {
+	ht := httptransport.NewHttpTransport()
+	ht.Name = "service-test"
+	ht.Port = freePort(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		_ = ht.ServeContext(ctx, routes.RootRouter)
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+
+	do := func(method string, path string) (*http.Response, string) {
+		req, _ := http.NewRequest(method, fmt.Sprintf("http://127.0.0.1:%d%s", ht.Port, path), nil)
+		resp, err := http.DefaultClient.Do(req)
+		NewWithT(t).Expect(err).To(BeNil())
+		defer resp.Body.Close()
+		data, _ := ioutil.ReadAll(resp.Body)
+		return resp, string(data)
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		resp, body := do(http.MethodGet, "/not-found")
+		NewWithT(t).Expect(resp.StatusCode).To(Equal(http.StatusNotFound))
+		NewWithT(t).Expect(resp.Header.Get("Content-Type")).To(Equal("application/json; charset=utf-8"))
+		NewWithT(t).Expect(body).To(ContainSubstring(`"key":"NotFound","code":404000000`))
+		NewWithT(t).Expect(body).To(ContainSubstring(`"sources":["service-test`))
+	})
+
+	t.Run("method not allowed", func(t *testing.T) {
+		resp, body := do(http.MethodPatch, "/demo/restful/1")
+		NewWithT(t).Expect(resp.StatusCode).To(Equal(http.StatusMethodNotAllowed))
+		NewWithT(t).Expect(resp.Header.Get("Allow")).To(Equal("DELETE, GET, HEAD, OPTIONS, PUT"))
+		NewWithT(t).Expect(body).To(ContainSubstring(`"key":"MethodNotAllowed","code":405000000`))
+	})
+
+	t.Run("head by get", func(t *testing.T) {
+		resp, body := do(http.MethodHead, "/demo/restful/123456")
+		NewWithT(t).Expect(resp.StatusCode).To(Equal(http.StatusOK))
+		NewWithT(t).Expect(resp.Header.Get("Content-Type")).To(Equal("application/json; charset=utf-8"))
+		NewWithT(t).Expect(body).To(BeEmpty())
+	})
+
+	t.Run("allowed methods", func(t *testing.T) {
+		NewWithT(t).Expect(ht.AllowedMethods("/demo/restful/123456")).To(Equal([]string{"GET", "HEAD", "PUT", "DELETE"}))
+		NewWithT(t).Expect(ht.AllowedMethods("/not-found")).To(BeEmpty())
+	})
+
+	t.Run("options", func(t *testing.T) {
+		resp, _ := do(http.MethodOptions, "/demo/restful/1")
+		NewWithT(t).Expect(resp.StatusCode).To(Equal(http.StatusNoContent))
+		NewWithT(t).Expect(resp.Header.Get("Allow")).To(Equal("DELETE, GET, HEAD, OPTIONS, PUT"))
+	})
+}
+
+func TestHttpTransportWithWebSocket(t *testing.T) {
+	ht := httptransport.NewHttpTransport()
+	ht.Port = freePort(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		_ = ht.ServeContext(ctx, courier.NewRouter(WebSocketEcho{}))
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+
+	c := &client.Client{
+		Host: "127.0.0.1",
+		Port: uint16(ht.Port),
+	}
+	c.SetDefaults()
+
+	t.Run("echo", func(t *testing.T) {
+		conn, err := c.DialWebSocket(context.Background(), WebSocketEcho{Prefix: "> "}, courier.Metadata{
+			httpx.HeaderSecWebSocketProtocol: {"chat, echo"},
+		})
+		NewWithT(t).Expect(err).To(BeNil())
+		defer conn.Close(httpx.WebSocketCloseNormalClosure, "")
+
+		NewWithT(t).Expect(conn.Subprotocol).To(Equal("echo"))
+
+		for _, msg := range []string{"hello", "world"} {
+			NewWithT(t).Expect(conn.WriteMessage(httpx.WebSocketTextMessage, []byte(msg))).To(BeNil())
+
+			typ, data, err := conn.ReadMessage()
+			NewWithT(t).Expect(err).To(BeNil())
+			NewWithT(t).Expect(typ).To(Equal(httpx.WebSocketTextMessage))
+			NewWithT(t).Expect(string(data)).To(Equal("> " + msg))
+		}
+
+		NewWithT(t).Expect(conn.WriteMessage(httpx.WebSocketTextMessage, []byte("close"))).To(BeNil())
+
+		_, _, err = conn.ReadMessage()
+		NewWithT(t).Expect(err).To(Equal(&httpx.WebSocketCloseError{Code: httpx.WebSocketCloseNormalClosure}))
+	})
+
+	t.Run("invalid parameters", func(t *testing.T) {
+		_, err := c.DialWebSocket(context.Background(), WebSocketEcho{})
+		NewWithT(t).Expect(err).NotTo(BeNil())
+
+		statusErr, ok := statuserror.IsStatusErr(err)
+		NewWithT(t).Expect(ok).To(BeTrue())
+		NewWithT(t).Expect(statusErr.StatusCode()).To(Equal(http.StatusBadRequest))
+	})
+
+	t.Run("not handshake", func(t *testing.T) {
+		resp, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d/ws?prefix=x", ht.Port))
+		NewWithT(t).Expect(err).To(BeNil())
+		_ = resp.Body.Close()
+
+		NewWithT(t).Expect(resp.StatusCode).To(Equal(http.StatusBadRequest))
+	})
+}
+
+func TestHttpTransportWithCompress(t *testing.T) {
+	ht := httptransport.NewHttpTransport()
+	ht.Port = freePort(t)
+	ht.MetricsPath = "/metrics"
+	ht.MetricsRegistry = handlers.NewMetricsRegistry()
+	ht.SetDefaults()
+	// compress inside default middlewares
+	ht.Middlewares = append(ht.Middlewares, handlers.CompressHandler(handlers.CompressOption{}))
+
+	router := courier.NewRouter(httptransport.Group("/"))
+	router.Register(courier.NewRouter(WebSocketEcho{}))
+	router.Register(courier.NewRouter(EventStreamOfChan{}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		_ = ht.ServeContext(ctx, router)
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+
+	t.Run("flush events", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d/events", ht.Port), nil)
+		req.Header.Set(httpx.HeaderAcceptEncoding, "gzip")
+
+		resp, err := (&http.Client{Timeout: 5 * time.Second}).Do(req)
+		NewWithT(t).Expect(err).To(BeNil())
+		defer resp.Body.Close()
+
+		select {
+		case events <- &httpx.ServerSentEvent{Data: "hello"}:
+		case <-time.After(time.Second):
+			t.Fatal("event stream not started")
+		}
+
+		line := make(chan string)
+		go func() {
+			l, _ := bufio.NewReader(resp.Body).ReadString('\n')
+			line <- l
+		}()
+
+		select {
+		case l := <-line:
+			NewWithT(t).Expect(l).To(Equal("data: hello\n"))
+		case <-time.After(time.Second):
+			t.Fatal("event not flushed")
+		}
+	})
+
+	t.Run("hijack for websocket", func(t *testing.T) {
+		c := &client.Client{
+			Host: "127.0.0.1",
+			Port: uint16(ht.Port),
+		}
+		c.SetDefaults()
+
+		conn, err := c.DialWebSocket(context.Background(), WebSocketEcho{Prefix: "> "})
+		NewWithT(t).Expect(err).To(BeNil())
+		defer conn.Close(httpx.WebSocketCloseNormalClosure, "")
+
+		NewWithT(t).Expect(conn.WriteMessage(httpx.WebSocketTextMessage, []byte("hello"))).To(BeNil())
+
+		_, data, err := conn.ReadMessage()
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(string(data)).To(Equal("> hello"))
+	})
+}
+
+var events = make(chan *httpx.ServerSentEvent)
+
+type EventStreamOfChan struct {
+	httpx.MethodGet
+}
+
+func (EventStreamOfChan) Path() string {
+	return "/events"
+}
+
+func (EventStreamOfChan) Output(ctx context.Context) (interface{}, error) {
+	return httpx.NewEventStream(events), nil
+}
+
+type WebSocketEcho struct {
+	httpx.MethodGet
+	Prefix string `name:"prefix" in:"query" validate:"@string[1,]"`
+}
+
+func (WebSocketEcho) Path() string {
+	return "/ws"
+}
+
+func (req WebSocketEcho) Output(ctx context.Context) (interface{}, error) {
+	return httpx.NewWebSocket(func(ctx context.Context, conn *httpx.WebSocketConn) error {
+		for {
+			typ, data, err := conn.ReadMessage()
+			if err != nil {
+				return err
+			}
+			if string(data) == "close" {
+				return nil
+			}
+			if err := conn.WriteMessage(typ, append([]byte(req.Prefix), data...)); err != nil {
+				return err
+			}
+		}
+	}).WithSubprotocols("echo"), nil
+}
+
+func freePort(t testing.TB) int {
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
 }
 
 func _TestHttpTransportWithHTTP2(t *testing.T) {
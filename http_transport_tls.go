@@ -0,0 +1,144 @@
+package httptransport
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-courier/logr"
+	"github.com/pkg/errors"
+)
+
+func (t *HttpTransport) tlsConfig(ctx context.Context) (*tls.Config, error) {
+	reloader, err := newCertReloader(t.CertFile, t.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.CertReloadInterval > 0 {
+		go reloader.Watch(ctx, t.CertReloadInterval)
+	}
+
+	c := &tls.Config{
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if t.ClientCAFile != "" {
+		data, err := os.ReadFile(t.ClientCAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "read client ca failed")
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.Errorf("invalid client ca %s", t.ClientCAFile)
+		}
+
+		c.ClientCAs = pool
+		c.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	if t.ClientAuth != tls.NoClientCert {
+		c.ClientAuth = t.ClientAuth
+	}
+
+	return c, nil
+}
+
+func newCertReloader(certFile string, keyFile string) (*certReloader, error) {
+	r := &certReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+	}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// certReloader holds server keypair and reloads it when files changed.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+func (r *certReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Reload loads keypair when cert file or key file modified, returns true when reloaded.
+func (r *certReloader) Reload() (bool, error) {
+	modTime, err := latestModTime(r.certFile, r.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.RLock()
+	changed := !modTime.Equal(r.modTime)
+	r.mu.RUnlock()
+
+	if !changed {
+		return false, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.modTime = modTime
+	r.mu.Unlock()
+
+	return true, nil
+}
+
+func (r *certReloader) Watch(ctx context.Context, interval time.Duration) {
+	l := logr.FromContext(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := r.Reload()
+			if err != nil {
+				// keep the previous keypair, cert file and key file may be writing.
+				l.Warn(errors.Wrap(err, "reload certificate failed"))
+				continue
+			}
+			if reloaded {
+				l.Info("certificate reloaded")
+			}
+		}
+	}
+}
+
+func latestModTime(files ...string) (time.Time, error) {
+	latest := time.Time{}
+
+	for _, f := range files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return latest, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+
+	return latest, nil
+}
@@ -0,0 +1,160 @@
+package httptransport_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-courier/courier"
+	"github.com/go-courier/httptransport"
+	"github.com/go-courier/httptransport/httpx"
+	"github.com/go-courier/httptransport/testdata/certs"
+	. "github.com/onsi/gomega"
+)
+
+type ClientCommonName struct {
+	httpx.MethodGet `path:"/cn"`
+}
+
+func (ClientCommonName) Output(ctx context.Context) (interface{}, error) {
+	cert := httptransport.ClientCertificateFromContext(ctx)
+	if cert == nil {
+		return "", nil
+	}
+	return cert.Subject.CommonName, nil
+}
+
+func TestHttpTransportWithMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	NewWithT(t).Expect(certs.Generate(dir, "client-a", "localhost")).To(BeNil())
+
+	router := courier.NewRouter(httptransport.BasePath("/tls"))
+	router.Register(courier.NewRouter(ClientCommonName{}))
+
+	ht := httptransport.NewHttpTransport()
+	ht.Port = freePort(t)
+	ht.CertFile = filepath.Join(dir, "cert.pem")
+	ht.KeyFile = filepath.Join(dir, "key.pem")
+	ht.ClientCAFile = filepath.Join(dir, "ca.pem")
+	ht.CertReloadInterval = 50 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		_ = ht.ServeContext(ctx, router)
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+
+	u := fmt.Sprintf("https://localhost:%d/tls/cn", ht.Port)
+
+	t.Run("request without client certificate", func(t *testing.T) {
+		c := newTLSClient(t, dir, false)
+
+		_, err := c.Get(u)
+		NewWithT(t).Expect(err).NotTo(BeNil())
+	})
+
+	t.Run("request with client certificate", func(t *testing.T) {
+		c := newTLSClient(t, dir, true)
+
+		resp, err := c.Get(u)
+		NewWithT(t).Expect(err).To(BeNil())
+		defer resp.Body.Close()
+
+		data, _ := io.ReadAll(resp.Body)
+		NewWithT(t).Expect(string(data)).To(Equal("client-a"))
+	})
+
+	t.Run("reload certificate", func(t *testing.T) {
+		dirNext := t.TempDir()
+		NewWithT(t).Expect(certs.Generate(dirNext, "client-b", "localhost")).To(BeNil())
+
+		c := newTLSClient(t, dir, true, filepath.Join(dirNext, "ca.pem"))
+
+		serial := func() string {
+			resp, err := c.Get(u)
+			NewWithT(t).Expect(err).To(BeNil())
+			defer resp.Body.Close()
+			return resp.TLS.PeerCertificates[0].SerialNumber.String()
+		}
+
+		before := serial()
+
+		for _, f := range []string{"key.pem", "cert.pem"} {
+			data, err := os.ReadFile(filepath.Join(dirNext, f))
+			NewWithT(t).Expect(err).To(BeNil())
+			NewWithT(t).Expect(os.WriteFile(filepath.Join(dir, f), data, 0600)).To(BeNil())
+		}
+
+		NewWithT(t).Eventually(serial, time.Second, 50*time.Millisecond).ShouldNot(Equal(before))
+	})
+}
+
+func TestHttpTransportWithTLSListener(t *testing.T) {
+	dir := t.TempDir()
+	NewWithT(t).Expect(certs.Generate(dir, "client-a", "localhost")).To(BeNil())
+
+	router := courier.NewRouter(httptransport.BasePath("/tls"))
+	router.Register(courier.NewRouter(ClientCommonName{}))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	NewWithT(t).Expect(err).To(BeNil())
+
+	ht := httptransport.NewHttpTransport()
+	ht.Listeners = []net.Listener{ln}
+	ht.CertFile = filepath.Join(dir, "cert.pem")
+	ht.KeyFile = filepath.Join(dir, "key.pem")
+	ht.ClientCAFile = filepath.Join(dir, "ca.pem")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		_ = ht.ServeContext(ctx, router)
+	}()
+
+	time.Sleep(200 * time.Millisecond)
+
+	resp, err := newTLSClient(t, dir, true).Get(fmt.Sprintf("https://localhost:%d/tls/cn", ln.Addr().(*net.TCPAddr).Port))
+	NewWithT(t).Expect(err).To(BeNil())
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(resp.Body)
+	NewWithT(t).Expect(string(data)).To(Equal("client-a"))
+}
+
+func newTLSClient(t *testing.T, dir string, withClientCert bool, extraCAFiles ...string) *http.Client {
+	pool := x509.NewCertPool()
+
+	for _, caFile := range append([]string{filepath.Join(dir, "ca.pem")}, extraCAFiles...) {
+		caData, err := os.ReadFile(caFile)
+		NewWithT(t).Expect(err).To(BeNil())
+		pool.AppendCertsFromPEM(caData)
+	}
+
+	tlsConfig := &tls.Config{RootCAs: pool}
+
+	if withClientCert {
+		cert, err := tls.LoadX509KeyPair(filepath.Join(dir, "client.pem"), filepath.Join(dir, "client-key.pem"))
+		NewWithT(t).Expect(err).To(BeNil())
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig:   tlsConfig,
+			DisableKeepAlives: true,
+		},
+	}
+}
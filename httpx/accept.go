@@ -0,0 +1,124 @@
+package httpx
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type AcceptValue struct {
+	Value  string
+	Q      float64
+	Params map[string]string
+}
+
+// ParseAccept parses header values with q-values, like Accept or Accept-Encoding,
+// sorted by q desc and keeping order of same q
+//
+//	Accept: text/html, application/xhtml+xml, application/xml;q=0.9, */*;q=0.8
+//	Accept-Encoding: gzip;q=1.0, deflate;q=0.5, *;q=0
+func ParseAccept(header string) []AcceptValue {
+	values := make([]AcceptValue, 0)
+
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		segments := strings.Split(part, ";")
+
+		v := AcceptValue{
+			Value: strings.ToLower(strings.TrimSpace(segments[0])),
+			Q:     1,
+		}
+
+		for _, param := range segments[1:] {
+			kv := strings.SplitN(strings.TrimSpace(param), "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+
+			key, value := strings.ToLower(strings.TrimSpace(kv[0])), strings.Trim(strings.TrimSpace(kv[1]), `"`)
+
+			if key == "q" {
+				if q, err := strconv.ParseFloat(value, 64); err == nil {
+					v.Q = q
+				}
+				continue
+			}
+
+			if v.Params == nil {
+				v.Params = map[string]string{}
+			}
+			v.Params[key] = value
+		}
+
+		values = append(values, v)
+	}
+
+	sort.SliceStable(values, func(i, j int) bool {
+		return values[i].Q > values[j].Q
+	})
+
+	return values
+}
+
+// Negotiate picks the offer (in server preference order) of highest q-value, false when nothing acceptable.
+// q-value of each offer resolved from the most specific matching value (RFC 9110),
+// specificity returns -1 when value not matched, and offers of q=0 rejected.
+// offers of same q-value ordered by their matching values.
+func Negotiate(values []AcceptValue, offers []string, specificity func(value string, offer string) int) (string, bool) {
+	picked, pickedQ, pickedIdx := -1, 0.0, 0
+
+	for i, offer := range offers {
+		q, idx, mostSpecific := 0.0, -1, -1
+
+		for j, v := range values {
+			if s := specificity(v.Value, offer); s > mostSpecific {
+				q, idx, mostSpecific = v.Q, j, s
+			}
+		}
+
+		if idx < 0 || q <= 0 {
+			continue
+		}
+
+		if picked < 0 || q > pickedQ || (q == pickedQ && idx < pickedIdx) {
+			picked, pickedQ, pickedIdx = i, q, idx
+		}
+	}
+
+	if picked < 0 {
+		return "", false
+	}
+
+	return offers[picked], true
+}
+
+// NegotiateContentType picks the best one of offers (in server preference order) for Accept,
+// returns the first offer when Accept empty, false when nothing acceptable.
+func NegotiateContentType(accept string, offers []string) (string, bool) {
+	if len(offers) == 0 {
+		return "", false
+	}
+
+	if strings.TrimSpace(accept) == "" {
+		return offers[0], true
+	}
+
+	return Negotiate(ParseAccept(accept), offers, mediaRangeSpecificity)
+}
+
+// mediaRangeSpecificity returns 2 for media type, 1 for type/*, 0 for */*
+func mediaRangeSpecificity(mediaRange string, mediaType string) int {
+	switch {
+	case mediaRange == mediaType:
+		return 2
+	case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(mediaType, mediaRange[0:len(mediaRange)-1]):
+		return 1
+	case mediaRange == "*/*":
+		return 0
+	}
+	return -1
+}
@@ -0,0 +1,51 @@
+package httpx
+
+import (
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestParseAccept(t *testing.T) {
+	NewWithT(t).Expect(ParseAccept("")).To(BeEmpty())
+
+	NewWithT(t).Expect(ParseAccept("deflate;q=0.5, gzip, *;q=0")).To(Equal([]AcceptValue{
+		{Value: "gzip", Q: 1},
+		{Value: "deflate", Q: 0.5},
+		{Value: "*", Q: 0},
+	}))
+
+	NewWithT(t).Expect(ParseAccept(`application/xml;q=0.9, text/html;charset="utf-8", */*;q=0.8`)).To(Equal([]AcceptValue{
+		{Value: "text/html", Q: 1, Params: map[string]string{"charset": "utf-8"}},
+		{Value: "application/xml", Q: 0.9},
+		{Value: "*/*", Q: 0.8},
+	}))
+}
+
+func TestNegotiateContentType(t *testing.T) {
+	offers := []string{MIME_JSON, MIME_XML}
+
+	cases := []struct {
+		accept     string
+		mediaType  string
+		acceptable bool
+	}{
+		{"", MIME_JSON, true},
+		{"*/*", MIME_JSON, true},
+		{"application/xml", MIME_XML, true},
+		{"text/html, application/xml;q=0.9, */*;q=0.8", MIME_XML, true},
+		{"application/*", MIME_JSON, true},
+		{"application/json;q=0.5, application/xml", MIME_XML, true},
+		{"text/html", "", false},
+		{"application/json;q=0", "", false},
+		{"*/*, application/json;q=0", MIME_XML, true},
+		{"application/*;q=0.5, application/json;q=0.1", MIME_XML, true},
+		{"*/*, application/*;q=0", "", false},
+	}
+
+	for _, c := range cases {
+		mediaType, acceptable := NegotiateContentType(c.accept, offers)
+		NewWithT(t).Expect(acceptable).To(Equal(c.acceptable), c.accept)
+		NewWithT(t).Expect(mediaType).To(Equal(c.mediaType), c.accept)
+	}
+}
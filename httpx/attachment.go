@@ -2,10 +2,19 @@ package httpx
 
 import (
 	"bytes"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/go-courier/courier"
 )
 
+const (
+	DispositionAttachment = "attachment"
+	DispositionInline     = "inline"
+)
+
 func NewAttachment(filename string, contentType string) *Attachment {
 	return &Attachment{
 		filename:    filename,
@@ -13,6 +22,8 @@ func NewAttachment(filename string, contentType string) *Attachment {
 	}
 }
 
+// Attachment buffers whole content in memory,
+// use AttachmentStream for large content
 type Attachment struct {
 	filename    string
 	contentType string
@@ -28,6 +39,217 @@ func (a *Attachment) ContentType() string {
 
 func (a *Attachment) Meta() courier.Metadata {
 	metadata := courier.Metadata{}
-	metadata.Add(HeaderContentDisposition, "attachment; filename="+a.filename)
+	metadata.Add(HeaderContentDisposition, FormatContentDisposition(DispositionAttachment, a.filename))
 	return metadata
 }
+
+// NewAttachmentStream creates attachment streaming from r,
+// r will be closed after response written when it is io.Closer
+func NewAttachmentStream(filename string, contentType string, r io.Reader) *AttachmentStream {
+	return &AttachmentStream{
+		filename:      filename,
+		contentType:   contentType,
+		disposition:   DispositionAttachment,
+		contentLength: -1,
+		r:             r,
+	}
+}
+
+// NewAttachmentStreamFunc creates attachment streaming by write, which writes to response directly
+func NewAttachmentStreamFunc(filename string, contentType string, write func(w io.Writer) error) *AttachmentStream {
+	return &AttachmentStream{
+		filename:      filename,
+		contentType:   contentType,
+		disposition:   DispositionAttachment,
+		contentLength: -1,
+		write:         write,
+	}
+}
+
+// openapi:strfmt binary
+// AttachmentStream writes content to response without buffering
+type AttachmentStream struct {
+	filename      string
+	contentType   string
+	disposition   string
+	contentLength int64
+	r             io.Reader
+	write         func(w io.Writer) error
+	pr            *io.PipeReader
+}
+
+// WithContentLength sets Content-Length when length of content known, otherwise response will be chunked
+func (a *AttachmentStream) WithContentLength(contentLength int64) *AttachmentStream {
+	a.contentLength = contentLength
+	return a
+}
+
+// Inline sets disposition as inline for displaying in browser
+func (a *AttachmentStream) Inline() *AttachmentStream {
+	a.disposition = DispositionInline
+	return a
+}
+
+func (a *AttachmentStream) ContentType() string {
+	if a.contentType == "" {
+		return MIME_OCTET_STREAM
+	}
+	return a.contentType
+}
+
+func (a *AttachmentStream) Meta() courier.Metadata {
+	metadata := courier.Metadata{}
+	metadata.Add(HeaderContentDisposition, FormatContentDisposition(a.disposition, a.filename))
+	if a.contentLength >= 0 {
+		metadata.Add(HeaderContentLength, strconv.FormatInt(a.contentLength, 10))
+	}
+	return metadata
+}
+
+func (a *AttachmentStream) Read(p []byte) (int, error) {
+	if a.write == nil {
+		return a.r.Read(p)
+	}
+
+	if a.pr == nil {
+		pr, pw := io.Pipe()
+		a.pr = pr
+
+		go func() {
+			pw.CloseWithError(a.write(pw))
+		}()
+	}
+
+	return a.pr.Read(p)
+}
+
+// WriteTo used by io.Copy, writes to w without intermediate buffer when created by write func
+func (a *AttachmentStream) WriteTo(w io.Writer) (int64, error) {
+	if a.write == nil {
+		return io.Copy(w, a.r)
+	}
+
+	if a.pr != nil {
+		return io.Copy(w, a.pr)
+	}
+
+	cw := &countingWriter{Writer: w}
+	err := a.write(cw)
+	return cw.n, err
+}
+
+func (a *AttachmentStream) Close() error {
+	if a.pr != nil {
+		return a.pr.Close()
+	}
+	if c, ok := a.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+type countingWriter struct {
+	io.Writer
+	n int64
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.n += int64(n)
+	return n, err
+}
+
+// FormatContentDisposition formats Content-Disposition of RFC 6266.
+// filename quoted when not a token,
+// and filename* of RFC 5987 added for non-ASCII filename, with ASCII fallback as filename.
+func FormatContentDisposition(disposition string, filename string) string {
+	if filename == "" {
+		return disposition
+	}
+
+	b := &strings.Builder{}
+	b.WriteString(disposition)
+	b.WriteString("; filename=")
+
+	if isASCII(filename) {
+		writeTokenOrQuoted(b, filename)
+		return b.String()
+	}
+
+	writeTokenOrQuoted(b, strings.Map(func(r rune) rune {
+		if r >= utf8.RuneSelf {
+			return '_'
+		}
+		return r
+	}, filename))
+
+	b.WriteString("; filename*=UTF-8''")
+
+	for _, c := range []byte(filename) {
+		if isAttrChar(c) {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(upperHex[c>>4])
+		b.WriteByte(upperHex[c&0x0f])
+	}
+
+	return b.String()
+}
+
+const upperHex = "0123456789ABCDEF"
+
+func isASCII(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+func writeTokenOrQuoted(b *strings.Builder, s string) {
+	isToken := true
+	for i := 0; i < len(s); i++ {
+		if !isTokenChar(s[i]) {
+			isToken = false
+			break
+		}
+	}
+
+	if isToken {
+		b.WriteString(s)
+		return
+	}
+
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '"' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20 || c == 0x7f:
+			// control chars not allowed in header
+			b.WriteByte('_')
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+}
+
+// tchar of https://www.rfc-editor.org/rfc/rfc7230#section-3.2.6
+func isTokenChar(c byte) bool {
+	return isAlphaNum(c) || strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0
+}
+
+// attr-char of https://www.rfc-editor.org/rfc/rfc5987#section-3.2.1
+func isAttrChar(c byte) bool {
+	return isAlphaNum(c) || strings.IndexByte("!#$&+-.^_`|~", c) >= 0
+}
+
+func isAlphaNum(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
@@ -2,6 +2,13 @@ package httpx
 
 import (
 	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/go-courier/httptransport/testify"
+	. "github.com/onsi/gomega"
 )
 
 func ExampleNewAttachment_withDefaultContentType() {
@@ -29,3 +36,83 @@ func ExampleNewAttachment() {
 	// Content-Disposition=attachment%3B+filename%3Dtest.txt
 	// {}
 }
+
+func ExampleFormatContentDisposition() {
+	fmt.Println(FormatContentDisposition(DispositionAttachment, "report.csv"))
+	fmt.Println(FormatContentDisposition(DispositionAttachment, `monthly "report".csv`))
+	fmt.Println(FormatContentDisposition(DispositionInline, "报表 2020.csv"))
+	// Output:
+	// attachment; filename=report.csv
+	// attachment; filename="monthly \"report\".csv"
+	// inline; filename="__ 2020.csv"; filename*=UTF-8''%E6%8A%A5%E8%A1%A8%202020.csv
+}
+
+func TestAttachmentStream(t *testing.T) {
+	t.Run("from reader", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		rw := testify.NewMockResponseWriter()
+
+		r := &closeRecorder{Reader: strings.NewReader("a,b\n1,2\n")}
+
+		err := ResponseFrom(NewAttachmentStream("data.csv", "text/csv", r).WithContentLength(8)).WriteTo(rw, req, nil)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(r.closed).To(BeTrue())
+
+		NewWithT(t).Expect(rw.Header().Get(HeaderContentLength)).To(Equal("8"))
+		NewWithT(t).Expect(string(rw.MustDumpResponse())).To(Equal(`HTTP/0.0 200 OK
+Content-Disposition: attachment; filename=data.csv
+Content-Type: text/csv
+
+a,b
+1,2
+`))
+	})
+
+	t.Run("from write func", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		rw := testify.NewMockResponseWriter()
+
+		a := NewAttachmentStreamFunc("数据.csv", "", func(w io.Writer) error {
+			for i := 0; i < 3; i++ {
+				if _, err := fmt.Fprintf(w, "%d\n", i); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Inline()
+
+		err := ResponseFrom(a).WriteTo(rw, req, nil)
+		NewWithT(t).Expect(err).To(BeNil())
+
+		NewWithT(t).Expect(string(rw.MustDumpResponse())).To(Equal(`HTTP/0.0 200 OK
+Content-Disposition: inline; filename=__.csv; filename*=UTF-8''%E6%95%B0%E6%8D%AE.csv
+Content-Type: application/octet-stream
+
+0
+1
+2
+`))
+	})
+
+	t.Run("read from write func", func(t *testing.T) {
+		a := NewAttachmentStreamFunc("data.txt", "", func(w io.Writer) error {
+			_, err := io.WriteString(w, "data")
+			return err
+		})
+		defer a.Close()
+
+		data, err := io.ReadAll(a)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(string(data)).To(Equal("data"))
+	})
+}
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.closed = true
+	return nil
+}
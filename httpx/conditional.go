@@ -0,0 +1,118 @@
+package httpx
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-courier/statuserror"
+	"github.com/pkg/errors"
+)
+
+// QuoteETag formats etag as entity tag, keeps it when quoted already
+func QuoteETag(etag string) string {
+	if etag == "" || strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`) {
+		return etag
+	}
+	return `"` + etag + `"`
+}
+
+// WeakETagOf computes weak entity tag by hashing data
+func WeakETagOf(data []byte) string {
+	sum := sha1.Sum(data)
+	return `W/"` + hex.EncodeToString(sum[:10]) + `"`
+}
+
+// Preconditions of unsafe methods for optimistic concurrency,
+// could be embedded in operator as parameters, then check with current state of resource.
+type Preconditions struct {
+	IfMatch           string `name:"If-Match,omitempty" in:"header"`
+	IfUnmodifiedSince string `name:"If-Unmodified-Since,omitempty" in:"header"`
+}
+
+// Check returns StatusErr 412 when preconditions not matched with current etag or modification time of resource
+func (p Preconditions) Check(etag string, lastModified time.Time) error {
+	if p.IfMatch != "" {
+		if !matchETags(p.IfMatch, etag, false) {
+			return statuserror.Wrap(errors.Errorf("If-Match %s not matched", p.IfMatch), http.StatusPreconditionFailed, "PreconditionFailed")
+		}
+		return nil
+	}
+
+	if p.IfUnmodifiedSince != "" && !lastModified.IsZero() {
+		t, err := http.ParseTime(p.IfUnmodifiedSince)
+		if err == nil && lastModified.Truncate(time.Second).After(t) {
+			return statuserror.Wrap(errors.Errorf("modified since %s", p.IfUnmodifiedSince), http.StatusPreconditionFailed, "PreconditionFailed")
+		}
+	}
+
+	return nil
+}
+
+// isNotModified evaluates If-None-Match and If-Modified-Since of safe methods
+func isNotModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if ifNoneMatch := r.Header.Get(HeaderIfNoneMatch); ifNoneMatch != "" {
+		// If-Modified-Since ignored when If-None-Match present
+		return etag != "" && matchETags(ifNoneMatch, etag, true)
+	}
+
+	if ifModifiedSince := r.Header.Get(HeaderIfModifiedSince); ifModifiedSince != "" && !lastModified.IsZero() {
+		t, err := http.ParseTime(ifModifiedSince)
+		return err == nil && !lastModified.Truncate(time.Second).After(t)
+	}
+
+	return false
+}
+
+// matchETags matches etag with list of entity tags in If-Match or If-None-Match.
+// weak comparison for If-None-Match, strong comparison for If-Match.
+func matchETags(list string, etag string, weak bool) bool {
+	if strings.TrimSpace(list) == "*" {
+		return etag != ""
+	}
+
+	etag = QuoteETag(etag)
+
+	if !weak && strings.HasPrefix(etag, "W/") {
+		return false
+	}
+
+	for _, tag := range strings.Split(list, ",") {
+		tag = strings.TrimSpace(tag)
+
+		if weak {
+			if strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+			continue
+		}
+
+		if tag == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// bufferedResponseWriter buffers body for computing etag
+type bufferedResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	bytes.Buffer
+}
+
+func (rw *bufferedResponseWriter) WriteHeader(statusCode int) {
+	rw.statusCode = statusCode
+}
+
+func (rw *bufferedResponseWriter) Write(p []byte) (int, error) {
+	return rw.Buffer.Write(p)
+}
@@ -0,0 +1,208 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/go-courier/httptransport/testify"
+	"github.com/go-courier/statuserror"
+	. "github.com/onsi/gomega"
+)
+
+type Catalog struct {
+	ID string
+}
+
+func (Catalog) ETag() string {
+	return "v1"
+}
+
+func (Catalog) LastModified() time.Time {
+	return time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+}
+
+type CatalogFile struct {
+	io.Reader
+	closed bool
+}
+
+func (CatalogFile) ETag() string {
+	return "v1"
+}
+
+func (f *CatalogFile) Close() error {
+	f.closed = true
+	return nil
+}
+
+func encodeJSON(response *Response) (Encode, error) {
+	return func(ctx context.Context, w io.Writer, v interface{}) error {
+		MaybeWriteHeader(ctx, w, "application/json", map[string]string{
+			"charset": "utf-8",
+		})
+		return json.NewEncoder(w).Encode(v)
+	}, nil
+}
+
+func TestConditionalRequests(t *testing.T) {
+	cases := map[string]struct {
+		method     string
+		header     http.Header
+		statusCode int
+	}{
+		"without conditions": {
+			http.MethodGet,
+			http.Header{},
+			http.StatusOK,
+		},
+		"If-None-Match matched": {
+			http.MethodGet,
+			http.Header{HeaderIfNoneMatch: {`"v0", W/"v1"`}},
+			http.StatusNotModified,
+		},
+		"If-None-Match *": {
+			http.MethodHead,
+			http.Header{HeaderIfNoneMatch: {`*`}},
+			http.StatusNotModified,
+		},
+		"If-None-Match not matched": {
+			http.MethodGet,
+			http.Header{HeaderIfNoneMatch: {`"v0"`}},
+			http.StatusOK,
+		},
+		"If-None-Match precedes If-Modified-Since": {
+			http.MethodGet,
+			http.Header{HeaderIfNoneMatch: {`"v0"`}, HeaderIfModifiedSince: {"Wed, 01 Jan 2020 00:00:00 GMT"}},
+			http.StatusOK,
+		},
+		"If-Modified-Since not modified": {
+			http.MethodGet,
+			http.Header{HeaderIfModifiedSince: {"Wed, 01 Jan 2020 00:00:00 GMT"}},
+			http.StatusNotModified,
+		},
+		"If-Modified-Since modified": {
+			http.MethodGet,
+			http.Header{HeaderIfModifiedSince: {"Tue, 31 Dec 2019 00:00:00 GMT"}},
+			http.StatusOK,
+		},
+		"unsafe method": {
+			http.MethodPut,
+			http.Header{HeaderIfNoneMatch: {`"v1"`}},
+			http.StatusOK,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			req, _ := http.NewRequest(c.method, "/", nil)
+			req.Header = c.header
+			rw := testify.NewMockResponseWriter()
+
+			err := ResponseFrom(&Catalog{ID: "1"}).WriteTo(rw, req, encodeJSON)
+			NewWithT(t).Expect(err).To(BeNil())
+
+			NewWithT(t).Expect(rw.StatusCode).To(Equal(c.statusCode))
+			NewWithT(t).Expect(rw.Header().Get(HeaderETag)).To(Equal(`"v1"`))
+			NewWithT(t).Expect(rw.Header().Get(HeaderLastModified)).To(Equal("Wed, 01 Jan 2020 00:00:00 GMT"))
+		})
+	}
+
+	t.Run("close content when not modified", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(HeaderIfNoneMatch, `"v1"`)
+		rw := testify.NewMockResponseWriter()
+
+		content := &CatalogFile{Reader: bytes.NewBufferString("{}")}
+
+		err := ResponseFrom(content).WriteTo(rw, req, encodeJSON)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusNotModified))
+		NewWithT(t).Expect(content.closed).To(BeTrue())
+	})
+
+	t.Run("weak etag", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		rw := testify.NewMockResponseWriter()
+
+		err := WithWeakETag()(map[string]string{"id": "1"}).WriteTo(rw, req, encodeJSON)
+		NewWithT(t).Expect(err).To(BeNil())
+
+		etag := rw.Header().Get(HeaderETag)
+		NewWithT(t).Expect(etag).To(Equal(WeakETagOf([]byte("{\"id\":\"1\"}\n"))))
+		NewWithT(t).Expect(string(rw.MustDumpResponse())).To(Equal(`HTTP/0.0 200 OK
+Content-Type: application/json; charset=utf-8
+Etag: ` + etag + `
+
+{"id":"1"}
+`))
+
+		t.Run("not modified", func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(HeaderIfNoneMatch, etag)
+			rw := testify.NewMockResponseWriter()
+
+			err := WithWeakETag()(map[string]string{"id": "1"}).WriteTo(rw, req, encodeJSON)
+			NewWithT(t).Expect(err).To(BeNil())
+			NewWithT(t).Expect(string(rw.MustDumpResponse())).To(Equal(`HTTP/0.0 304 Not Modified
+Etag: ` + etag + `
+
+`))
+		})
+	})
+}
+
+func TestPreconditions(t *testing.T) {
+	lastModified := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	cases := map[string]struct {
+		preconditions Preconditions
+		failed        bool
+	}{
+		"none": {
+			Preconditions{},
+			false,
+		},
+		"If-Match matched": {
+			Preconditions{IfMatch: `"v0", "v1"`},
+			false,
+		},
+		"If-Match *": {
+			Preconditions{IfMatch: `*`},
+			false,
+		},
+		"If-Match weak": {
+			Preconditions{IfMatch: `W/"v1"`},
+			true,
+		},
+		"If-Match not matched": {
+			Preconditions{IfMatch: `"v0"`},
+			true,
+		},
+		"If-Unmodified-Since": {
+			Preconditions{IfUnmodifiedSince: "Wed, 01 Jan 2020 00:00:00 GMT"},
+			false,
+		},
+		"If-Unmodified-Since modified": {
+			Preconditions{IfUnmodifiedSince: "Tue, 31 Dec 2019 00:00:00 GMT"},
+			true,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			err := c.preconditions.Check("v1", lastModified)
+			if !c.failed {
+				NewWithT(t).Expect(err).To(BeNil())
+				return
+			}
+			statusErr, ok := statuserror.IsStatusErr(err)
+			NewWithT(t).Expect(ok).To(BeTrue())
+			NewWithT(t).Expect(statusErr.StatusCode()).To(Equal(http.StatusPreconditionFailed))
+		})
+	}
+}
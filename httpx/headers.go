@@ -4,7 +4,48 @@ const (
 	HeaderUserAgent          = "User-Agent"
 	HeaderContentType        = "Content-Type"
 	HeaderContentDisposition = "Content-Disposition"
+	HeaderContentEncoding    = "Content-Encoding"
+	HeaderContentLength      = "Content-Length"
+	HeaderAccept             = "Accept"
+	HeaderAcceptEncoding     = "Accept-Encoding"
+	HeaderVary               = "Vary"
+	HeaderCacheControl       = "Cache-Control"
+	HeaderConnection         = "Connection"
+	HeaderUpgrade            = "Upgrade"
+	HeaderETag               = "ETag"
+	HeaderLastModified       = "Last-Modified"
+	HeaderIfMatch            = "If-Match"
+	HeaderIfNoneMatch        = "If-None-Match"
+	HeaderIfModifiedSince    = "If-Modified-Since"
+	HeaderIfUnmodifiedSince  = "If-Unmodified-Since"
+	HeaderRange              = "Range"
+	HeaderIfRange            = "If-Range"
+	HeaderAcceptRanges       = "Accept-Ranges"
+	HeaderContentRange       = "Content-Range"
 	HeaderRequestID          = "X-Request-ID"
+	HeaderTraceParent        = "Traceparent"
+	HeaderTraceState         = "Tracestate"
+	HeaderIdempotencyKey     = "Idempotency-Key"
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+	HeaderRetryAfter         = "Retry-After"
+	HeaderRateLimitLimit     = "RateLimit-Limit"
+	HeaderRateLimitRemaining = "RateLimit-Remaining"
+	HeaderRateLimitReset     = "RateLimit-Reset"
 	HeaderForwardedFor       = "X-Forwarded-For"
 	HeaderRealIP             = "X-Real-IP"
+
+	HeaderOrigin                        = "Origin"
+	HeaderAccessControlRequestMethod    = "Access-Control-Request-Method"
+	HeaderAccessControlRequestHeaders   = "Access-Control-Request-Headers"
+	HeaderAccessControlAllowOrigin      = "Access-Control-Allow-Origin"
+	HeaderAccessControlAllowMethods     = "Access-Control-Allow-Methods"
+	HeaderAccessControlAllowHeaders     = "Access-Control-Allow-Headers"
+	HeaderAccessControlAllowCredentials = "Access-Control-Allow-Credentials"
+	HeaderAccessControlExposeHeaders    = "Access-Control-Expose-Headers"
+	HeaderAccessControlMaxAge           = "Access-Control-Max-Age"
+
+	HeaderSecWebSocketKey      = "Sec-WebSocket-Key"
+	HeaderSecWebSocketAccept   = "Sec-WebSocket-Accept"
+	HeaderSecWebSocketVersion  = "Sec-WebSocket-Version"
+	HeaderSecWebSocketProtocol = "Sec-WebSocket-Protocol"
 )
@@ -3,6 +3,7 @@ package httpx
 import (
 	"net/http"
 	"net/url"
+	"time"
 )
 
 type ContentTypeDescriber interface {
@@ -13,6 +14,16 @@ type StatusCodeDescriber interface {
 	StatusCode() int
 }
 
+// ETagDescriber value with entity tag, like `"v1"` or `W/"v1"`, quoted when not
+type ETagDescriber interface {
+	ETag() string
+}
+
+// LastModifiedDescriber value with modification time
+type LastModifiedDescriber interface {
+	LastModified() time.Time
+}
+
 type CookiesDescriber interface {
 	Cookies() []*http.Cookie
 }
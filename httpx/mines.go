@@ -4,7 +4,10 @@ package httpx
 const (
 	MIME_OCTET_STREAM      = "application/octet-stream"
 	MIME_JSON              = "application/json"
+	MIME_PROBLEM_JSON      = "application/problem+json"
 	MIME_XML               = "application/xml"
+	MIME_PLAIN             = "text/plain"
+	MIME_EVENT_STREAM      = "text/event-stream"
 	MIME_FORM_URLENCODED   = "application/x-www-form-urlencoded"
 	MIME_MULTIPART_FORMDAT = "multipart/form-data"
 	MIME_PROTOBUF          = "application/x-protobuf"
@@ -0,0 +1,64 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/go-courier/statuserror"
+)
+
+// ProblemDetailsFrom converts StatusErr to problem details
+func ProblemDetailsFrom(statusErr *statuserror.StatusErr) *ProblemDetails {
+	return &ProblemDetails{
+		Title:          statusErr.Msg,
+		Status:         statusErr.StatusCode(),
+		Detail:         statusErr.Desc,
+		Instance:       statusErr.ID,
+		Key:            statusErr.Key,
+		Code:           statusErr.Code,
+		CanBeTalkError: statusErr.CanBeTalkError,
+		Sources:        statusErr.Sources,
+		Errors:         statusErr.ErrorFields,
+	}
+}
+
+// ProblemDetails of application/problem+json
+// https://www.rfc-editor.org/rfc/rfc9457
+type ProblemDetails struct {
+	// uri of problem type, about:blank when empty
+	Type string `json:"type,omitempty"`
+	// summary of problem type, msg of StatusErr
+	Title string `json:"title"`
+	// http status code
+	Status int `json:"status"`
+	// explanation of this occurrence, desc of StatusErr
+	Detail string `json:"detail,omitempty"`
+	// identifies this occurrence, id of StatusErr
+	Instance string `json:"instance,omitempty"`
+
+	// key of StatusErr
+	Key string `json:"key"`
+	// code of StatusErr
+	Code int `json:"code"`
+	// can be talk error
+	CanBeTalkError bool `json:"canBeTalkError,omitempty"`
+	// error tracing
+	Sources []string `json:"sources,omitempty"`
+	// invalid fields of request
+	Errors statuserror.ErrorFields `json:"errors,omitempty"`
+}
+
+func (ProblemDetails) ContentType() string {
+	return MIME_PROBLEM_JSON
+}
+
+func (p ProblemDetails) StatusCode() int {
+	return p.Status
+}
+
+// EncodeProblemDetails encodes problem details as application/problem+json
+func EncodeProblemDetails(ctx context.Context, w io.Writer, v interface{}) error {
+	MaybeWriteHeader(ctx, w, MIME_PROBLEM_JSON, nil)
+	return json.NewEncoder(w).Encode(v)
+}
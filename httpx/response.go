@@ -7,6 +7,7 @@ import (
 	"net/http"
 	"net/textproto"
 	"net/url"
+	"time"
 
 	"github.com/go-courier/courier"
 	"github.com/go-courier/statuserror"
@@ -56,6 +57,40 @@ func WithContentType(contentType string) ResponseWrapper {
 	}
 }
 
+// WithoutCompression opts out response compression of handlers.CompressHandler
+func WithoutCompression() ResponseWrapper {
+	return func(v interface{}) *Response {
+		resp := ResponseFrom(v)
+		resp.NoCompression = true
+		return resp
+	}
+}
+
+func WithETag(etag string) ResponseWrapper {
+	return func(v interface{}) *Response {
+		resp := ResponseFrom(v)
+		resp.ETag = etag
+		return resp
+	}
+}
+
+func WithLastModified(lastModified time.Time) ResponseWrapper {
+	return func(v interface{}) *Response {
+		resp := ResponseFrom(v)
+		resp.LastModified = lastModified
+		return resp
+	}
+}
+
+// WithWeakETag computes weak ETag by hashing encoded body when ETag not declared
+func WithWeakETag() ResponseWrapper {
+	return func(v interface{}) *Response {
+		resp := ResponseFrom(v)
+		resp.WeakETag = true
+		return resp
+	}
+}
+
 func Metadata(key string, values ...string) courier.Metadata {
 	return courier.Metadata{
 		key: values,
@@ -116,6 +151,14 @@ func ResponseFrom(v interface{}) *Response {
 		response.StatusCode = statusDescriber.StatusCode()
 	}
 
+	if etagDescriber, ok := v.(ETagDescriber); ok {
+		response.ETag = etagDescriber.ETag()
+	}
+
+	if lastModifiedDescriber, ok := v.(LastModifiedDescriber); ok {
+		response.LastModified = lastModifiedDescriber.LastModified()
+	}
+
 	return response
 }
 
@@ -131,6 +174,12 @@ type Response struct {
 	Location    *url.URL         `json:"-"`
 	ContentType string           `json:"-"`
 	StatusCode  int              `json:"-"`
+	// validators for conditional requests
+	ETag         string    `json:"-"`
+	LastModified time.Time `json:"-"`
+	WeakETag     bool      `json:"-"`
+	// opts out compression, see WithoutCompression
+	NoCompression bool `json:"-"`
 }
 
 func (response *Response) Unwrap() error {
@@ -178,6 +227,11 @@ func (response *Response) WriteTo(rw http.ResponseWriter, r *http.Request, resol
 		response.Value = nil
 	}()
 
+	// closes content even not written, like 304 of conditional requests
+	if c, ok := response.Value.(io.ReadCloser); ok {
+		defer c.Close()
+	}
+
 	if upgrader, ok := response.Value.(Upgrader); ok {
 		return upgrader.Upgrade(rw, r)
 	}
@@ -194,6 +248,10 @@ func (response *Response) WriteTo(rw http.ResponseWriter, r *http.Request, resol
 		}
 	}
 
+	if response.NoCompression {
+		DisableCompression(r.Context())
+	}
+
 	if response.Metadata != nil {
 		header := rw.Header()
 		for key, values := range response.Metadata {
@@ -220,6 +278,19 @@ func (response *Response) WriteTo(rw http.ResponseWriter, r *http.Request, resol
 		return nil
 	}
 
+	if response.ETag != "" {
+		rw.Header().Set(HeaderETag, QuoteETag(response.ETag))
+	}
+
+	if !response.LastModified.IsZero() {
+		rw.Header().Set(HeaderLastModified, response.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if response.StatusCode == http.StatusOK && isNotModified(r, response.ETag, response.LastModified) {
+		rw.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
 	if response.ContentType != "" {
 		rw.Header().Set(HeaderContentType, response.ContentType)
 	}
@@ -232,13 +303,14 @@ func (response *Response) WriteTo(rw http.ResponseWriter, r *http.Request, resol
 			return err
 		}
 	case io.Reader:
-		rw.WriteHeader(response.StatusCode)
+		if rs, ok := v.(io.ReadSeeker); ok && isRangeRequest(r, response.StatusCode) && isAtStart(rs) {
+			// Range requests with If-Range by ETag or Last-Modified,
+			// single range as 206, multiple ranges as multipart/byteranges, unsatisfiable as 416
+			http.ServeContent(rw, r, "", response.LastModified, rs)
+			return nil
+		}
 
-		defer func() {
-			if c, ok := v.(io.Closer); ok {
-				c.Close()
-			}
-		}()
+		rw.WriteHeader(response.StatusCode)
 
 		if _, err := io.Copy(rw, v); err != nil {
 			return err
@@ -249,6 +321,10 @@ func (response *Response) WriteTo(rw http.ResponseWriter, r *http.Request, resol
 			return err
 		}
 
+		if response.WeakETag && response.ETag == "" && response.StatusCode == http.StatusOK {
+			return writeWithWeakETag(rw, r, response, encodeTo)
+		}
+
 		if err := encodeTo(ContextWithStatusCode(r.Context(), response.StatusCode), rw, response.Value); err != nil {
 			return err
 		}
@@ -257,6 +333,60 @@ func (response *Response) WriteTo(rw http.ResponseWriter, r *http.Request, resol
 	return nil
 }
 
+func isRangeRequest(r *http.Request, statusCode int) bool {
+	if statusCode != http.StatusOK || (r.Method != http.MethodGet && r.Method != http.MethodHead) {
+		return false
+	}
+	return r.Header.Get(HeaderRange) != "" || r.Header.Get(HeaderIfRange) != ""
+}
+
+// isAtStart checks current offset of rs, ranges of content advanced by operator not supported
+func isAtStart(rs io.Seeker) bool {
+	offset, err := rs.Seek(0, io.SeekCurrent)
+	return err == nil && offset == 0
+}
+
+func writeWithWeakETag(rw http.ResponseWriter, r *http.Request, response *Response, encodeTo Encode) error {
+	buffered := &bufferedResponseWriter{ResponseWriter: rw}
+
+	if err := encodeTo(ContextWithStatusCode(r.Context(), response.StatusCode), buffered, response.Value); err != nil {
+		return err
+	}
+
+	etag := WeakETagOf(buffered.Bytes())
+	rw.Header().Set(HeaderETag, etag)
+
+	if isNotModified(r, etag, response.LastModified) {
+		rw.Header().Del(HeaderContentType)
+		rw.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
+	statusCode := buffered.statusCode
+	if statusCode == 0 {
+		statusCode = response.StatusCode
+	}
+
+	rw.WriteHeader(statusCode)
+
+	_, err := buffered.WriteTo(rw)
+	return err
+}
+
+type contextKeyCompressionDisabler struct{}
+
+// ContextWithCompressionDisabler sets func disabling compression of current response, called by handlers.CompressHandler
+func ContextWithCompressionDisabler(ctx context.Context, disable func()) context.Context {
+	return context.WithValue(ctx, contextKeyCompressionDisabler{}, disable)
+}
+
+// DisableCompression disables compression of current response, should be called before writing body
+func DisableCompression(ctx context.Context) {
+	if disable, ok := ctx.Value(contextKeyCompressionDisabler{}).(func()); ok {
+		disable()
+	}
+}
+
 type contextKeyStatusCode struct{}
 
 func ContextWithStatusCode(ctx context.Context, statusCode int) context.Context {
@@ -1,6 +1,7 @@
 package httpx
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"io"
@@ -66,6 +67,23 @@ func TestResponseFrom(t *testing.T) {
 }
 
 func TestResponse_WriteTo(t *testing.T) {
+	t.Run("without compression", func(t *testing.T) {
+		disabled := false
+
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req = req.WithContext(ContextWithCompressionDisabler(req.Context(), func() {
+			disabled = true
+		}))
+		rw := testify.NewMockResponseWriter()
+
+		err := WithoutCompression()(bytes.NewBufferString("text")).WriteTo(rw, req, nil)
+		NewWithT(t).Expect(err).To(BeNil())
+
+		NewWithT(t).Expect(disabled).To(BeTrue())
+		NewWithT(t).Expect(rw.Header()).NotTo(HaveKey(HeaderContentEncoding))
+		NewWithT(t).Expect(rw.String()).To(Equal("text"))
+	})
+
 	t.Run("redirect", func(t *testing.T) {
 		req, _ := http.NewRequest(http.MethodPost, "/", nil)
 		rw := testify.NewMockResponseWriter()
@@ -192,4 +210,103 @@ Content-Type: text/plain
 
 123123123`))
 	})
+	t.Run("range", func(t *testing.T) {
+		newRequest := func(header http.Header) *http.Request {
+			req, _ := http.NewRequest(http.MethodGet, "/", nil)
+			req.Header = header
+			return req
+		}
+
+		content := func() *Response {
+			return WithETag("v1")(WithContentType("text/plain")(bytes.NewReader([]byte("0123456789"))))
+		}
+
+		t.Run("full", func(t *testing.T) {
+			rw := testify.NewMockResponseWriter()
+
+			_ = content().WriteTo(rw, newRequest(http.Header{}), nil)
+
+			NewWithT(t).Expect(string(rw.MustDumpResponse())).To(Equal(`HTTP/0.0 200 OK
+Content-Type: text/plain
+Etag: "v1"
+
+0123456789`))
+		})
+
+		t.Run("without range", func(t *testing.T) {
+			rw := testify.NewMockResponseWriter()
+
+			_ = ResponseFrom(bytes.NewReader([]byte("0123456789"))).WriteTo(rw, newRequest(http.Header{}), nil)
+
+			NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusOK))
+			NewWithT(t).Expect(rw.Header()).NotTo(HaveKey(HeaderContentType))
+			NewWithT(t).Expect(rw.Header()).NotTo(HaveKey(HeaderAcceptRanges))
+			NewWithT(t).Expect(rw.String()).To(Equal("0123456789"))
+		})
+
+		t.Run("advanced reader", func(t *testing.T) {
+			r := bytes.NewReader([]byte("0123456789"))
+			_, _ = r.Seek(5, io.SeekStart)
+
+			rw := testify.NewMockResponseWriter()
+
+			_ = WithContentType("text/plain")(r).WriteTo(rw, newRequest(http.Header{HeaderRange: {"bytes=0-1"}}), nil)
+
+			NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusOK))
+			NewWithT(t).Expect(rw.String()).To(Equal("56789"))
+		})
+
+		t.Run("single", func(t *testing.T) {
+			rw := testify.NewMockResponseWriter()
+
+			_ = content().WriteTo(rw, newRequest(http.Header{HeaderRange: {"bytes=2-4"}}), nil)
+
+			NewWithT(t).Expect(string(rw.MustDumpResponse())).To(Equal(`HTTP/0.0 206 Partial Content
+Accept-Ranges: bytes
+Content-Range: bytes 2-4/10
+Content-Type: text/plain
+Etag: "v1"
+
+234`))
+		})
+
+		t.Run("multiple", func(t *testing.T) {
+			rw := testify.NewMockResponseWriter()
+
+			_ = content().WriteTo(rw, newRequest(http.Header{HeaderRange: {"bytes=0-1,8-"}}), nil)
+
+			NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusPartialContent))
+			NewWithT(t).Expect(rw.Header().Get(HeaderContentType)).To(HavePrefix("multipart/byteranges; boundary="))
+
+			dump := string(rw.MustDumpResponse())
+			NewWithT(t).Expect(dump).To(ContainSubstring("Content-Range: bytes 0-1/10"))
+			NewWithT(t).Expect(dump).To(ContainSubstring("Content-Range: bytes 8-9/10"))
+		})
+
+		t.Run("unsatisfiable", func(t *testing.T) {
+			rw := testify.NewMockResponseWriter()
+
+			_ = content().WriteTo(rw, newRequest(http.Header{HeaderRange: {"bytes=20-"}}), nil)
+
+			NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusRequestedRangeNotSatisfiable))
+			NewWithT(t).Expect(rw.Header().Get(HeaderContentRange)).To(Equal("bytes */10"))
+		})
+
+		t.Run("If-Range matched", func(t *testing.T) {
+			rw := testify.NewMockResponseWriter()
+
+			_ = content().WriteTo(rw, newRequest(http.Header{HeaderRange: {"bytes=2-4"}, HeaderIfRange: {`"v1"`}}), nil)
+
+			NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusPartialContent))
+		})
+
+		t.Run("If-Range not matched", func(t *testing.T) {
+			rw := testify.NewMockResponseWriter()
+
+			_ = content().WriteTo(rw, newRequest(http.Header{HeaderRange: {"bytes=2-4"}, HeaderIfRange: {`"v0"`}}), nil)
+
+			NewWithT(t).Expect(rw.StatusCode).To(Equal(http.StatusOK))
+			NewWithT(t).Expect(rw.Header().Get(HeaderContentLength)).To(Equal("10"))
+		})
+	})
 }
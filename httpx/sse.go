@@ -0,0 +1,199 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ServerSentEvent of text/event-stream
+// https://html.spec.whatwg.org/multipage/server-sent-events.html
+type ServerSentEvent struct {
+	ID    string
+	Event string
+	// string and []byte written as is, others encoded as json
+	Data interface{}
+	// reconnection time for client
+	Retry time.Duration
+}
+
+func (e *ServerSentEvent) WriteTo(w io.Writer) (int64, error) {
+	b := bytes.NewBuffer(nil)
+
+	if e.ID != "" {
+		writeEventField(b, "id", e.ID)
+	}
+	if e.Event != "" {
+		writeEventField(b, "event", e.Event)
+	}
+	if e.Retry > 0 {
+		writeEventField(b, "retry", strconv.FormatInt(e.Retry.Milliseconds(), 10))
+	}
+
+	data, err := e.data()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, line := range strings.Split(data, "\n") {
+		writeEventField(b, "data", line)
+	}
+
+	b.WriteByte('\n')
+
+	return b.WriteTo(w)
+}
+
+func (e *ServerSentEvent) data() (string, error) {
+	switch v := e.Data.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+}
+
+func writeEventField(b *bytes.Buffer, field string, value string) {
+	b.WriteString(field)
+	b.WriteString(": ")
+	// line breaks not allowed in value
+	b.WriteString(strings.NewReplacer("\r", "", "\n", "").Replace(value))
+	b.WriteByte('\n')
+}
+
+// EventIterator returns next event, io.EOF when no more events
+type EventIterator func(ctx context.Context) (*ServerSentEvent, error)
+
+const defaultEventStreamHeartbeat = 15 * time.Second
+
+// NewEventStream creates Server-Sent Events response from channel, stream ends when channel closed
+func NewEventStream(events <-chan *ServerSentEvent) *EventStream {
+	return &EventStream{
+		events:    events,
+		heartbeat: defaultEventStreamHeartbeat,
+	}
+}
+
+// NewEventStreamFrom creates Server-Sent Events response from iterator, stream ends when io.EOF returned
+func NewEventStreamFrom(next EventIterator) *EventStream {
+	return &EventStream{
+		next:      next,
+		heartbeat: defaultEventStreamHeartbeat,
+	}
+}
+
+// EventStream writes and flushes each event until events end or client disconnected.
+// Use WithSchema to describe payload of events in openapi.
+type EventStream struct {
+	events    <-chan *ServerSentEvent
+	next      EventIterator
+	heartbeat time.Duration
+}
+
+// WithHeartbeat sets interval of heartbeat comments for keeping connection alive, default 15s, disabled when <= 0
+func (s *EventStream) WithHeartbeat(interval time.Duration) *EventStream {
+	s.heartbeat = interval
+	return s
+}
+
+func (EventStream) ContentType() string {
+	return MIME_EVENT_STREAM
+}
+
+func (s *EventStream) Upgrade(rw http.ResponseWriter, r *http.Request) error {
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	events, errs := s.events, (<-chan error)(nil)
+	if s.next != nil {
+		events, errs = iterateEvents(ctx, s.next)
+	}
+
+	header := rw.Header()
+	header.Set(HeaderContentType, MIME_EVENT_STREAM)
+	header.Set(HeaderCacheControl, "no-cache")
+	// disable buffering of nginx
+	header.Set("X-Accel-Buffering", "no")
+
+	rw.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(rw)
+	_ = rc.Flush()
+
+	var heartbeat <-chan time.Time
+	if s.heartbeat > 0 {
+		ticker := time.NewTicker(s.heartbeat)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-heartbeat:
+			if _, err := io.WriteString(rw, ":\n\n"); err != nil {
+				return nil
+			}
+		case err := <-errs:
+			if err == io.EOF {
+				return nil
+			}
+			// headers already sent, so report as error event
+			e := &ServerSentEvent{Event: "error", Data: ResponseFrom(err).Value}
+			if _, err := e.WriteTo(rw); err != nil {
+				return nil
+			}
+			_ = rc.Flush()
+			return nil
+		case e, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if e == nil {
+				continue
+			}
+			if _, err := e.WriteTo(rw); err != nil {
+				return nil
+			}
+		}
+
+		_ = rc.Flush()
+	}
+}
+
+func iterateEvents(ctx context.Context, next EventIterator) (<-chan *ServerSentEvent, <-chan error) {
+	events := make(chan *ServerSentEvent)
+	errs := make(chan error, 1)
+
+	go func() {
+		for {
+			e, err := next(ctx)
+			if err != nil {
+				errs <- err
+				return
+			}
+
+			select {
+			case events <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return events, errs
+}
@@ -0,0 +1,91 @@
+package httpx
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-courier/statuserror"
+	. "github.com/onsi/gomega"
+)
+
+func TestServerSentEvent(t *testing.T) {
+	t.Run("write fields", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+
+		_, err := (&ServerSentEvent{
+			ID:    "1",
+			Event: "update",
+			Retry: time.Second,
+			Data:  "line1\nline2",
+		}).WriteTo(rw)
+
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(rw.Body.String()).To(Equal("id: 1\nevent: update\nretry: 1000\ndata: line1\ndata: line2\n\n"))
+	})
+
+	t.Run("write json data", func(t *testing.T) {
+		rw := httptest.NewRecorder()
+
+		_, err := (&ServerSentEvent{
+			Data: map[string]string{"name": "x"},
+		}).WriteTo(rw)
+
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(rw.Body.String()).To(Equal("data: {\"name\":\"x\"}\n\n"))
+	})
+}
+
+func TestEventStream(t *testing.T) {
+	t.Run("from channel", func(t *testing.T) {
+		events := make(chan *ServerSentEvent, 2)
+		events <- &ServerSentEvent{ID: "1", Data: "a"}
+		events <- &ServerSentEvent{ID: "2", Data: "b"}
+		close(events)
+
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+
+		err := ResponseFrom(NewEventStream(events)).WriteTo(rw, req, nil)
+		NewWithT(t).Expect(err).To(BeNil())
+
+		NewWithT(t).Expect(rw.Header().Get(HeaderContentType)).To(Equal(MIME_EVENT_STREAM))
+		NewWithT(t).Expect(rw.Header().Get(HeaderCacheControl)).To(Equal("no-cache"))
+		NewWithT(t).Expect(rw.Flushed).To(BeTrue())
+		NewWithT(t).Expect(rw.Body.String()).To(Equal("id: 1\ndata: a\n\nid: 2\ndata: b\n\n"))
+	})
+
+	t.Run("from iterator with error", func(t *testing.T) {
+		i := 0
+
+		s := NewEventStreamFrom(func(ctx context.Context) (*ServerSentEvent, error) {
+			i++
+			if i > 1 {
+				return nil, statuserror.Wrap(io.ErrUnexpectedEOF, http.StatusBadGateway, "Upstream")
+			}
+			return &ServerSentEvent{Data: "a"}, nil
+		})
+
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+
+		err := s.Upgrade(rw, req)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(rw.Body.String()).To(HavePrefix("data: a\n\nevent: error\ndata: {\"key\":\"Upstream\",\"code\":502000000"))
+	})
+
+	t.Run("heartbeat until client disconnected", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		rw := httptest.NewRecorder()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/", nil)
+
+		err := NewEventStream(make(chan *ServerSentEvent)).WithHeartbeat(10*time.Millisecond).Upgrade(rw, req)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(rw.Body.String()).To(HavePrefix(":\n\n:\n\n"))
+	})
+}
@@ -0,0 +1,181 @@
+package httpx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+type TraceID [16]byte
+
+func (id TraceID) IsValid() bool {
+	return id != TraceID{}
+}
+
+func (id TraceID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+type SpanID [8]byte
+
+func (id SpanID) IsValid() bool {
+	return id != SpanID{}
+}
+
+func (id SpanID) String() string {
+	return hex.EncodeToString(id[:])
+}
+
+func NewTraceID() (id TraceID) {
+	_, _ = rand.Read(id[:])
+	return
+}
+
+func NewSpanID() (id SpanID) {
+	_, _ = rand.Read(id[:])
+	return
+}
+
+const traceFlagSampled = 0x01
+
+// TraceParent of W3C Trace Context
+// https://www.w3.org/TR/trace-context/#traceparent-header
+type TraceParent struct {
+	TraceID TraceID
+	// id of span of caller
+	SpanID SpanID
+	Flags  byte
+}
+
+// ParseTraceParent parses traceparent header of version 00,
+// fields after flags of higher versions ignored as spec required.
+func ParseTraceParent(s string) (TraceParent, error) {
+	tp := TraceParent{}
+
+	parts := strings.Split(strings.TrimSpace(s), "-")
+	if len(parts) < 4 {
+		return tp, errors.Errorf("invalid traceparent %q", s)
+	}
+
+	version, err := decodeHex(parts[0], 1)
+	if err != nil || version[0] == 0xff || (version[0] == 0 && len(parts) != 4) {
+		return tp, errors.Errorf("invalid version of traceparent %q", s)
+	}
+
+	traceID, err := decodeHex(parts[1], len(tp.TraceID))
+	if err != nil {
+		return tp, errors.Errorf("invalid trace-id of traceparent %q", s)
+	}
+	copy(tp.TraceID[:], traceID)
+
+	spanID, err := decodeHex(parts[2], len(tp.SpanID))
+	if err != nil {
+		return tp, errors.Errorf("invalid parent-id of traceparent %q", s)
+	}
+	copy(tp.SpanID[:], spanID)
+
+	flags, err := decodeHex(parts[3], 1)
+	if err != nil {
+		return tp, errors.Errorf("invalid trace-flags of traceparent %q", s)
+	}
+	tp.Flags = flags[0]
+
+	if !tp.IsValid() {
+		return tp, errors.Errorf("all zero trace-id or parent-id of traceparent %q", s)
+	}
+
+	return tp, nil
+}
+
+// decodeHex decodes lowercase hex of n bytes
+func decodeHex(s string, n int) ([]byte, error) {
+	if len(s) != n*2 || strings.ToLower(s) != s {
+		return nil, errors.Errorf("invalid hex %q", s)
+	}
+	return hex.DecodeString(s)
+}
+
+func (tp TraceParent) IsValid() bool {
+	return tp.TraceID.IsValid() && tp.SpanID.IsValid()
+}
+
+func (tp TraceParent) Sampled() bool {
+	return tp.Flags&traceFlagSampled != 0
+}
+
+func (tp TraceParent) String() string {
+	return "00-" + tp.TraceID.String() + "-" + tp.SpanID.String() + "-" + hex.EncodeToString([]byte{tp.Flags})
+}
+
+// TraceContext correlates requests across services by X-Request-ID, traceparent and tracestate
+type TraceContext struct {
+	RequestID string
+	// with SpanID of current service, used as parent of downstream requests
+	TraceParent TraceParent
+	// id of span of caller, empty when trace started by current service
+	ParentSpanID SpanID
+	TraceState   string
+}
+
+// TraceContextFromRequest parses trace context of ingress request.
+// request id generated when missing;
+// trace continued with new span id when traceparent valid, otherwise new trace started.
+func TraceContextFromRequest(r *http.Request) TraceContext {
+	tc := TraceContext{
+		RequestID: r.Header.Get(HeaderRequestID),
+	}
+
+	if tc.RequestID == "" {
+		tc.RequestID = uuid.New().String()
+	}
+
+	if tp, err := ParseTraceParent(r.Header.Get(HeaderTraceParent)); err == nil {
+		tc.TraceParent = tp
+		tc.ParentSpanID = tp.SpanID
+		// tracestate must be dropped when traceparent invalid
+		tc.TraceState = strings.Join(r.Header.Values(HeaderTraceState), ",")
+	} else {
+		tc.TraceParent = TraceParent{
+			TraceID: NewTraceID(),
+			Flags:   traceFlagSampled,
+		}
+	}
+
+	tc.TraceParent.SpanID = NewSpanID()
+
+	return tc
+}
+
+// InjectTo sets headers of trace context, headers exist kept
+func (tc TraceContext) InjectTo(header http.Header) {
+	if tc.RequestID != "" && header.Get(HeaderRequestID) == "" {
+		header.Set(HeaderRequestID, tc.RequestID)
+	}
+
+	if tc.TraceParent.IsValid() && header.Get(HeaderTraceParent) == "" {
+		header.Set(HeaderTraceParent, tc.TraceParent.String())
+
+		if tc.TraceState != "" {
+			header.Set(HeaderTraceState, tc.TraceState)
+		}
+	}
+}
+
+type contextKeyTraceContext struct{}
+
+func ContextWithTraceContext(ctx context.Context, tc TraceContext) context.Context {
+	return context.WithValue(ctx, contextKeyTraceContext{}, tc)
+}
+
+func TraceContextFromContext(ctx context.Context) (TraceContext, bool) {
+	if ctx == nil {
+		return TraceContext{}, false
+	}
+	tc, ok := ctx.Value(contextKeyTraceContext{}).(TraceContext)
+	return tc, ok
+}
@@ -0,0 +1,101 @@
+package httpx
+
+import (
+	"net/http"
+	"testing"
+
+	. "github.com/onsi/gomega"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	cases := map[string]struct {
+		traceParent string
+		valid       bool
+	}{
+		"valid": {
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			true,
+		},
+		"higher version with more fields": {
+			"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-what-the-future-will-be",
+			true,
+		},
+		"version 00 with more fields": {
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-more",
+			false,
+		},
+		"version ff": {
+			"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			false,
+		},
+		"uppercase": {
+			"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+			false,
+		},
+		"zero trace id": {
+			"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+			false,
+		},
+		"zero parent id": {
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+			false,
+		},
+		"short trace id": {
+			"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01",
+			false,
+		},
+		"empty": {
+			"",
+			false,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			tp, err := ParseTraceParent(c.traceParent)
+			if !c.valid {
+				NewWithT(t).Expect(err).NotTo(BeNil())
+				return
+			}
+			NewWithT(t).Expect(err).To(BeNil())
+			NewWithT(t).Expect(tp.TraceID.String()).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+			NewWithT(t).Expect(tp.SpanID.String()).To(Equal("00f067aa0ba902b7"))
+			NewWithT(t).Expect(tp.Sampled()).To(BeTrue())
+		})
+	}
+}
+
+func TestTraceContextFromRequest(t *testing.T) {
+	t.Run("continue trace", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(HeaderRequestID, "request-1")
+		req.Header.Set(HeaderTraceParent, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+		req.Header.Set(HeaderTraceState, "congo=t61rcWkgMzE")
+
+		tc := TraceContextFromRequest(req)
+		NewWithT(t).Expect(tc.RequestID).To(Equal("request-1"))
+		NewWithT(t).Expect(tc.TraceParent.TraceID.String()).To(Equal("4bf92f3577b34da6a3ce929d0e0e4736"))
+		NewWithT(t).Expect(tc.ParentSpanID.String()).To(Equal("00f067aa0ba902b7"))
+		NewWithT(t).Expect(tc.TraceParent.SpanID).NotTo(Equal(tc.ParentSpanID))
+		NewWithT(t).Expect(tc.TraceParent.Sampled()).To(BeFalse())
+		NewWithT(t).Expect(tc.TraceState).To(Equal("congo=t61rcWkgMzE"))
+
+		header := http.Header{}
+		tc.InjectTo(header)
+		NewWithT(t).Expect(header.Get(HeaderRequestID)).To(Equal("request-1"))
+		NewWithT(t).Expect(header.Get(HeaderTraceParent)).To(Equal("00-4bf92f3577b34da6a3ce929d0e0e4736-" + tc.TraceParent.SpanID.String() + "-00"))
+		NewWithT(t).Expect(header.Get(HeaderTraceState)).To(Equal("congo=t61rcWkgMzE"))
+	})
+
+	t.Run("start trace", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(HeaderTraceParent, "invalid")
+		req.Header.Set(HeaderTraceState, "congo=t61rcWkgMzE")
+
+		tc := TraceContextFromRequest(req)
+		NewWithT(t).Expect(tc.RequestID).NotTo(BeEmpty())
+		NewWithT(t).Expect(tc.TraceParent.IsValid()).To(BeTrue())
+		NewWithT(t).Expect(tc.ParentSpanID.IsValid()).To(BeFalse())
+		NewWithT(t).Expect(tc.TraceState).To(BeEmpty())
+	})
+}
@@ -38,3 +38,15 @@ func ClientIPByHeaderForwardedFor(headerForwardedFor string) string {
 func ClientIPByHeaderRealIP(headerRealIP string) string {
 	return strings.TrimSpace(headerRealIP)
 }
+
+// AddVary adds key to Vary of header when not listed
+func AddVary(header http.Header, key string) {
+	for _, v := range header.Values(HeaderVary) {
+		for _, listed := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(listed), key) {
+				return
+			}
+		}
+	}
+	header.Add(HeaderVary, key)
+}
@@ -39,3 +39,14 @@ func TestClientIPByHeaderRealIP(t *testing.T) {
 func TestGetClientIPByHeaderRealIP(t *testing.T) {
 	NewWithT(t).Expect(ClientIPByHeaderForwardedFor("203.0.113.195, 70.41.3.18, 150.172.238.178")).To(Equal("203.0.113.195"))
 }
+
+func TestAddVary(t *testing.T) {
+	header := http.Header{}
+	header.Set(HeaderVary, "Accept, Origin")
+
+	AddVary(header, "origin")
+	AddVary(header, HeaderAcceptEncoding)
+	AddVary(header, HeaderAcceptEncoding)
+
+	NewWithT(t).Expect(header.Values(HeaderVary)).To(Equal([]string{"Accept, Origin", "Accept-Encoding"}))
+}
@@ -0,0 +1,209 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-courier/statuserror"
+	"github.com/pkg/errors"
+)
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// NewWebSocketKey returns random Sec-WebSocket-Key for handshake of client
+func NewWebSocketKey() string {
+	p := make([]byte, 16)
+	_, _ = rand.Read(p)
+	return base64.StdEncoding.EncodeToString(p)
+}
+
+// WebSocketAcceptKey returns Sec-WebSocket-Accept for Sec-WebSocket-Key
+func WebSocketAcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// NewWebSocket creates websocket response, serve will be called with connection after handshake.
+// Connection closed with WebSocketCloseNormalClosure when serve returns nil,
+// otherwise with WebSocketCloseInternalError.
+func NewWebSocket(serve func(ctx context.Context, conn *WebSocketConn) error) *WebSocket {
+	return &WebSocket{
+		serve:          serve,
+		maxMessageSize: defaultWebSocketMaxMessageSize,
+	}
+}
+
+// WebSocket implements Upgrader by RFC 6455
+type WebSocket struct {
+	serve          func(ctx context.Context, conn *WebSocketConn) error
+	subprotocols   []string
+	checkOrigin    func(r *http.Request) bool
+	maxMessageSize int64
+	pingInterval   time.Duration
+}
+
+// WithSubprotocols sets supported subprotocols, the first one requested by client will be selected
+func (ws *WebSocket) WithSubprotocols(subprotocols ...string) *WebSocket {
+	ws.subprotocols = subprotocols
+	return ws
+}
+
+// WithCheckOrigin sets checker of Origin, default only same host allowed when Origin present
+func (ws *WebSocket) WithCheckOrigin(checkOrigin func(r *http.Request) bool) *WebSocket {
+	ws.checkOrigin = checkOrigin
+	return ws
+}
+
+// WithMaxMessageSize sets max bytes of message read, default 1 MB when <= 0
+func (ws *WebSocket) WithMaxMessageSize(maxMessageSize int64) *WebSocket {
+	ws.maxMessageSize = maxMessageSize
+	return ws
+}
+
+// WithPingInterval sets interval of sending ping frames, disabled when <= 0
+func (ws *WebSocket) WithPingInterval(interval time.Duration) *WebSocket {
+	ws.pingInterval = interval
+	return ws
+}
+
+func (ws *WebSocket) Upgrade(rw http.ResponseWriter, r *http.Request) error {
+	if err := ws.checkHandshake(rw, r); err != nil {
+		return err
+	}
+
+	netConn, brw, err := http.NewResponseController(rw).Hijack()
+	if err != nil {
+		return statuserror.Wrap(err, http.StatusInternalServerError, "WebSocketUnsupported")
+	}
+
+	// clear deadlines set by server
+	_ = netConn.SetDeadline(time.Time{})
+
+	subprotocol := ws.selectSubprotocol(r)
+
+	b := bytes.NewBufferString("HTTP/1.1 101 Switching Protocols\r\n")
+
+	header := rw.Header().Clone()
+	header.Set(HeaderUpgrade, "websocket")
+	header.Set(HeaderConnection, "Upgrade")
+	header.Set(HeaderSecWebSocketAccept, WebSocketAcceptKey(r.Header.Get(HeaderSecWebSocketKey)))
+	if subprotocol != "" {
+		header.Set(HeaderSecWebSocketProtocol, subprotocol)
+	}
+	_ = header.Write(b)
+	b.WriteString("\r\n")
+
+	if _, err := netConn.Write(b.Bytes()); err != nil {
+		_ = netConn.Close()
+		// hijacked, could not response error any more
+		return nil
+	}
+
+	conn := NewWebSocketConn(netConn, brw.Reader, true)
+	conn.Subprotocol = subprotocol
+	conn.SetMaxMessageSize(ws.maxMessageSize)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	if ws.pingInterval > 0 {
+		go func() {
+			ticker := time.NewTicker(ws.pingInterval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if err := conn.Ping(nil); err != nil {
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	if err := ws.serve(ctx, conn); err != nil {
+		_ = conn.Close(WebSocketCloseInternalError, err.Error())
+		return nil
+	}
+
+	_ = conn.Close(WebSocketCloseNormalClosure, "")
+	return nil
+}
+
+func (ws *WebSocket) checkHandshake(rw http.ResponseWriter, r *http.Request) error {
+	if r.Method != http.MethodGet {
+		return statuserror.Wrap(errors.Errorf("websocket handshake should be GET, but got %s", r.Method), http.StatusMethodNotAllowed, "BadWebSocketHandshake")
+	}
+
+	if !headerContainsToken(r.Header, HeaderConnection, "upgrade") || !headerContainsToken(r.Header, HeaderUpgrade, "websocket") {
+		return statuserror.Wrap(errors.New("missing upgrade headers of websocket handshake"), http.StatusBadRequest, "BadWebSocketHandshake")
+	}
+
+	if r.Header.Get(HeaderSecWebSocketVersion) != "13" {
+		rw.Header().Set(HeaderSecWebSocketVersion, "13")
+		return statuserror.Wrap(errors.Errorf("unsupported websocket version %s", r.Header.Get(HeaderSecWebSocketVersion)), http.StatusUpgradeRequired, "BadWebSocketHandshake")
+	}
+
+	if key, err := base64.StdEncoding.DecodeString(r.Header.Get(HeaderSecWebSocketKey)); err != nil || len(key) != 16 {
+		return statuserror.Wrap(errors.New("invalid Sec-WebSocket-Key"), http.StatusBadRequest, "BadWebSocketHandshake")
+	}
+
+	checkOrigin := ws.checkOrigin
+	if checkOrigin == nil {
+		checkOrigin = isSameOrigin
+	}
+
+	if !checkOrigin(r) {
+		return statuserror.Wrap(errors.Errorf("origin %s not allowed", r.Header.Get(HeaderOrigin)), http.StatusForbidden, "WebSocketOriginNotAllowed")
+	}
+
+	return nil
+}
+
+func (ws *WebSocket) selectSubprotocol(r *http.Request) string {
+	for _, v := range r.Header.Values(HeaderSecWebSocketProtocol) {
+		for _, p := range strings.Split(v, ",") {
+			p = strings.TrimSpace(p)
+			for _, supported := range ws.subprotocols {
+				if p == supported {
+					return p
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func isSameOrigin(r *http.Request) bool {
+	origin := r.Header.Get(HeaderOrigin)
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+func headerContainsToken(header http.Header, key string, token string) bool {
+	for _, v := range header.Values(key) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
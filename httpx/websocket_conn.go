@@ -0,0 +1,396 @@
+package httpx
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// message types of websocket
+const (
+	WebSocketTextMessage   = 1
+	WebSocketBinaryMessage = 2
+)
+
+const (
+	websocketOpContinuation = 0
+	websocketOpClose        = 8
+	websocketOpPing         = 9
+	websocketOpPong         = 10
+)
+
+// close codes of websocket
+// https://www.rfc-editor.org/rfc/rfc6455#section-7.4.1
+const (
+	WebSocketCloseNormalClosure    = 1000
+	WebSocketCloseGoingAway        = 1001
+	WebSocketCloseProtocolError    = 1002
+	WebSocketCloseUnsupportedData  = 1003
+	WebSocketCloseNoStatusReceived = 1005
+	WebSocketCloseInvalidPayload   = 1007
+	WebSocketClosePolicyViolation  = 1008
+	WebSocketCloseMessageTooBig    = 1009
+	WebSocketCloseInternalError    = 1011
+)
+
+const (
+	defaultWebSocketMaxMessageSize = 1 << 20 // 1 MB
+	maxControlFramePayloadSize     = 125
+)
+
+var ErrWebSocketClosed = errors.New("websocket closed")
+
+// WebSocketCloseError returned by ReadMessage when close frame received,
+// or connection failed for protocol error
+type WebSocketCloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *WebSocketCloseError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("websocket closed with %d", e.Code)
+	}
+	return fmt.Sprintf("websocket closed with %d: %s", e.Code, e.Reason)
+}
+
+// NewWebSocketConn creates websocket connection after handshake.
+// br is for data already buffered of rwc, could be nil.
+func NewWebSocketConn(rwc io.ReadWriteCloser, br *bufio.Reader, isServer bool) *WebSocketConn {
+	if br == nil {
+		br = bufio.NewReader(rwc)
+	}
+	return &WebSocketConn{
+		rwc:            rwc,
+		br:             br,
+		isServer:       isServer,
+		maxMessageSize: defaultWebSocketMaxMessageSize,
+	}
+}
+
+// WebSocketConn of RFC 6455.
+// ReadMessage should be called by one goroutine, other methods are safe for concurrent use.
+// Ping frames are answered when reading.
+type WebSocketConn struct {
+	// selected subprotocol of handshake
+	Subprotocol string
+
+	rwc            io.ReadWriteCloser
+	br             *bufio.Reader
+	isServer       bool
+	maxMessageSize int64
+
+	readErr error
+
+	mu        sync.Mutex
+	closeSent bool
+}
+
+// SetMaxMessageSize sets max bytes of message read, default 1 MB when <= 0
+func (c *WebSocketConn) SetMaxMessageSize(maxMessageSize int64) {
+	if maxMessageSize <= 0 {
+		maxMessageSize = defaultWebSocketMaxMessageSize
+	}
+	c.maxMessageSize = maxMessageSize
+}
+
+// SetReadDeadline sets read deadline of underlying connection when supported
+func (c *WebSocketConn) SetReadDeadline(t time.Time) error {
+	if d, ok := c.rwc.(interface{ SetReadDeadline(t time.Time) error }); ok {
+		return d.SetReadDeadline(t)
+	}
+	return errors.New("read deadline not supported")
+}
+
+// SetWriteDeadline sets write deadline of underlying connection when supported
+func (c *WebSocketConn) SetWriteDeadline(t time.Time) error {
+	if d, ok := c.rwc.(interface{ SetWriteDeadline(t time.Time) error }); ok {
+		return d.SetWriteDeadline(t)
+	}
+	return errors.New("write deadline not supported")
+}
+
+// ReadMessage reads next message of WebSocketTextMessage or WebSocketBinaryMessage,
+// *WebSocketCloseError returned when connection closed by peer.
+func (c *WebSocketConn) ReadMessage() (messageType int, data []byte, err error) {
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+
+	defer func() {
+		if err != nil {
+			c.readErr = err
+		}
+	}()
+
+	for {
+		h, err := c.readFrameHeader()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if h.rsv != 0 {
+			return 0, nil, c.fail(WebSocketCloseProtocolError, "reserved bits set")
+		}
+
+		if h.masked != c.isServer {
+			if c.isServer {
+				return 0, nil, c.fail(WebSocketCloseProtocolError, "frame from client should be masked")
+			}
+			return 0, nil, c.fail(WebSocketCloseProtocolError, "frame from server should not be masked")
+		}
+
+		switch h.opcode {
+		case websocketOpClose, websocketOpPing, websocketOpPong:
+			if !h.fin || h.length > maxControlFramePayloadSize {
+				return 0, nil, c.fail(WebSocketCloseProtocolError, "invalid control frame")
+			}
+
+			payload, err := c.readPayload(h)
+			if err != nil {
+				return 0, nil, err
+			}
+
+			switch h.opcode {
+			case websocketOpPing:
+				if err := c.writeFrame(websocketOpPong, payload); err != nil && err != ErrWebSocketClosed {
+					return 0, nil, err
+				}
+			case websocketOpClose:
+				return 0, nil, c.handleClose(payload)
+			}
+		case websocketOpContinuation, WebSocketTextMessage, WebSocketBinaryMessage:
+			if h.opcode == websocketOpContinuation {
+				if messageType == 0 {
+					return 0, nil, c.fail(WebSocketCloseProtocolError, "unexpected continuation frame")
+				}
+			} else {
+				if messageType != 0 {
+					return 0, nil, c.fail(WebSocketCloseProtocolError, "expect continuation frame")
+				}
+				messageType = h.opcode
+			}
+
+			// checked before allocating payload, length of frame could be up to 1<<63-1
+			if h.length > uint64(c.maxMessageSize-int64(len(data))) {
+				return 0, nil, c.fail(WebSocketCloseMessageTooBig, fmt.Sprintf("message should not be larger than %d bytes", c.maxMessageSize))
+			}
+
+			payload, err := c.readPayload(h)
+			if err != nil {
+				return 0, nil, err
+			}
+
+			data = append(data, payload...)
+
+			if h.fin {
+				if messageType == WebSocketTextMessage && !utf8.Valid(data) {
+					return 0, nil, c.fail(WebSocketCloseInvalidPayload, "invalid utf8 text")
+				}
+				return messageType, data, nil
+			}
+		default:
+			return 0, nil, c.fail(WebSocketCloseProtocolError, fmt.Sprintf("unknown opcode %d", h.opcode))
+		}
+	}
+}
+
+// WriteMessage writes message of WebSocketTextMessage or WebSocketBinaryMessage in single frame
+func (c *WebSocketConn) WriteMessage(messageType int, data []byte) error {
+	if messageType != WebSocketTextMessage && messageType != WebSocketBinaryMessage {
+		return errors.Errorf("unsupported message type %d", messageType)
+	}
+	return c.writeFrame(messageType, data)
+}
+
+// Ping sends ping frame, pong frame will be ignored when reading
+func (c *WebSocketConn) Ping(data []byte) error {
+	if len(data) > maxControlFramePayloadSize {
+		return errors.New("ping payload too large")
+	}
+	return c.writeFrame(websocketOpPing, data)
+}
+
+// Close sends close frame with code and reason when not sent, then closes underlying connection
+func (c *WebSocketConn) Close(code int, reason string) error {
+	_ = c.writeClose(code, reason)
+	return c.rwc.Close()
+}
+
+func (c *WebSocketConn) handleClose(payload []byte) error {
+	closeErr := &WebSocketCloseError{Code: WebSocketCloseNoStatusReceived}
+
+	switch {
+	case len(payload) == 1:
+		return c.fail(WebSocketCloseProtocolError, "invalid close frame")
+	case len(payload) >= 2:
+		closeErr.Code = int(binary.BigEndian.Uint16(payload))
+		closeErr.Reason = string(payload[2:])
+
+		if !validCloseCode(closeErr.Code) {
+			return c.fail(WebSocketCloseProtocolError, "invalid close code")
+		}
+		if !utf8.ValidString(closeErr.Reason) {
+			return c.fail(WebSocketCloseInvalidPayload, "invalid utf8 close reason")
+		}
+	}
+
+	// echo close
+	echoCode := closeErr.Code
+	if echoCode == WebSocketCloseNoStatusReceived {
+		echoCode = WebSocketCloseNormalClosure
+	}
+	_ = c.writeClose(echoCode, "")
+
+	return closeErr
+}
+
+// fail sends close frame and closes underlying connection for protocol errors
+func (c *WebSocketConn) fail(code int, reason string) error {
+	_ = c.Close(code, reason)
+	return &WebSocketCloseError{Code: code, Reason: reason}
+}
+
+func (c *WebSocketConn) writeClose(code int, reason string) error {
+	// reason should be fit in control frame
+	if len(reason) > maxControlFramePayloadSize-2 {
+		reason = reason[:maxControlFramePayloadSize-2]
+	}
+
+	payload := make([]byte, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	copy(payload[2:], reason)
+
+	return c.writeFrame(websocketOpClose, payload)
+}
+
+func (c *WebSocketConn) writeFrame(opcode int, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closeSent {
+		return ErrWebSocketClosed
+	}
+
+	if opcode == websocketOpClose {
+		c.closeSent = true
+	}
+
+	length := len(payload)
+
+	frame := make([]byte, 0, 14+length)
+	frame = append(frame, 0x80|byte(opcode))
+
+	maskBit := byte(0)
+	if !c.isServer {
+		maskBit = 0x80
+	}
+
+	switch {
+	case length <= maxControlFramePayloadSize:
+		frame = append(frame, maskBit|byte(length))
+	case length <= 0xffff:
+		frame = append(frame, maskBit|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(length))
+	default:
+		frame = append(frame, maskBit|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(length))
+	}
+
+	if c.isServer {
+		frame = append(frame, payload...)
+	} else {
+		var maskKey [4]byte
+		if _, err := rand.Read(maskKey[:]); err != nil {
+			return err
+		}
+		frame = append(frame, maskKey[:]...)
+		offset := len(frame)
+		frame = append(frame, payload...)
+		maskBytes(maskKey, frame[offset:])
+	}
+
+	_, err := c.rwc.Write(frame)
+	return err
+}
+
+type websocketFrameHeader struct {
+	fin     bool
+	rsv     byte
+	opcode  int
+	masked  bool
+	length  uint64
+	maskKey [4]byte
+}
+
+func (c *WebSocketConn) readFrameHeader() (*websocketFrameHeader, error) {
+	var p [8]byte
+
+	if _, err := io.ReadFull(c.br, p[:2]); err != nil {
+		return nil, err
+	}
+
+	h := &websocketFrameHeader{
+		fin:    p[0]&0x80 != 0,
+		rsv:    p[0] & 0x70,
+		opcode: int(p[0] & 0x0f),
+		masked: p[1]&0x80 != 0,
+		length: uint64(p[1] & 0x7f),
+	}
+
+	switch h.length {
+	case 126:
+		if _, err := io.ReadFull(c.br, p[:2]); err != nil {
+			return nil, err
+		}
+		h.length = uint64(binary.BigEndian.Uint16(p[:2]))
+	case 127:
+		if _, err := io.ReadFull(c.br, p[:8]); err != nil {
+			return nil, err
+		}
+		h.length = binary.BigEndian.Uint64(p[:8])
+		if h.length > 1<<63-1 {
+			return nil, c.fail(WebSocketCloseProtocolError, "invalid payload length")
+		}
+	}
+
+	if h.masked {
+		if _, err := io.ReadFull(c.br, h.maskKey[:]); err != nil {
+			return nil, err
+		}
+	}
+
+	return h, nil
+}
+
+func (c *WebSocketConn) readPayload(h *websocketFrameHeader) ([]byte, error) {
+	payload := make([]byte, h.length)
+	if _, err := io.ReadFull(c.br, payload); err != nil {
+		return nil, err
+	}
+	if h.masked {
+		maskBytes(h.maskKey, payload)
+	}
+	return payload, nil
+}
+
+func maskBytes(key [4]byte, p []byte) {
+	for i := range p {
+		p[i] ^= key[i%4]
+	}
+}
+
+func validCloseCode(code int) bool {
+	switch code {
+	case 1000, 1001, 1002, 1003, 1007, 1008, 1009, 1010, 1011:
+		return true
+	}
+	return code >= 3000 && code <= 4999
+}
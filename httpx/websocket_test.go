@@ -0,0 +1,231 @@
+package httpx
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-courier/statuserror"
+	. "github.com/onsi/gomega"
+)
+
+func TestWebSocketAcceptKey(t *testing.T) {
+	// example of RFC 6455
+	NewWithT(t).Expect(WebSocketAcceptKey("dGhlIHNhbXBsZSBub25jZQ==")).To(Equal("s3pPLMBiTxaQ9kYGzzhZRbK+xOo="))
+}
+
+func TestWebSocketHandshake(t *testing.T) {
+	ws := NewWebSocket(nil)
+
+	newRequest := func() *http.Request {
+		req, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1/ws", nil)
+		req.Header.Set(HeaderConnection, "keep-alive, Upgrade")
+		req.Header.Set(HeaderUpgrade, "websocket")
+		req.Header.Set(HeaderSecWebSocketVersion, "13")
+		req.Header.Set(HeaderSecWebSocketKey, NewWebSocketKey())
+		return req
+	}
+
+	cases := map[string]struct {
+		modify     func(req *http.Request)
+		statusCode int
+	}{
+		"post": {
+			func(req *http.Request) { req.Method = http.MethodPost },
+			http.StatusMethodNotAllowed,
+		},
+		"missing upgrade": {
+			func(req *http.Request) { req.Header.Del(HeaderUpgrade) },
+			http.StatusBadRequest,
+		},
+		"unsupported version": {
+			func(req *http.Request) { req.Header.Set(HeaderSecWebSocketVersion, "8") },
+			http.StatusUpgradeRequired,
+		},
+		"invalid key": {
+			func(req *http.Request) { req.Header.Set(HeaderSecWebSocketKey, "key") },
+			http.StatusBadRequest,
+		},
+		"cross origin": {
+			func(req *http.Request) { req.Header.Set(HeaderOrigin, "http://other.com") },
+			http.StatusForbidden,
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			req := newRequest()
+			c.modify(req)
+
+			err := ws.Upgrade(httptest.NewRecorder(), req)
+			statusErr, ok := statuserror.IsStatusErr(err)
+			NewWithT(t).Expect(ok).To(BeTrue())
+			NewWithT(t).Expect(statusErr.StatusCode()).To(Equal(c.statusCode))
+		})
+	}
+
+	t.Run("subprotocol", func(t *testing.T) {
+		req := newRequest()
+		req.Header.Set(HeaderSecWebSocketProtocol, "chat, echo")
+
+		NewWithT(t).Expect(NewWebSocket(nil).WithSubprotocols("echo", "chat").selectSubprotocol(req)).To(Equal("chat"))
+		NewWithT(t).Expect(NewWebSocket(nil).WithSubprotocols("other").selectSubprotocol(req)).To(Equal(""))
+	})
+}
+
+func TestWebSocketConn(t *testing.T) {
+	t.Run("messages", func(t *testing.T) {
+		server, client := newWebSocketConnPair(t)
+
+		go func() {
+			_ = client.WriteMessage(WebSocketTextMessage, []byte("hello"))
+		}()
+
+		typ, data, err := server.ReadMessage()
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(typ).To(Equal(WebSocketTextMessage))
+		NewWithT(t).Expect(string(data)).To(Equal("hello"))
+
+		large := bytes.Repeat([]byte("x"), 70000)
+
+		go func() {
+			_ = server.WriteMessage(WebSocketBinaryMessage, large)
+		}()
+
+		typ, data, err = client.ReadMessage()
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(typ).To(Equal(WebSocketBinaryMessage))
+		NewWithT(t).Expect(data).To(Equal(large))
+	})
+
+	t.Run("fragmented with ping", func(t *testing.T) {
+		server, client := newWebSocketConnPair(t)
+
+		rwc := client.rwc
+
+		// masked by zero key
+		_, _ = rwc.Write([]byte{0x01, 0x83, 0, 0, 0, 0, 'h', 'e', 'l'})
+		_, _ = rwc.Write([]byte{0x89, 0x80, 0, 0, 0, 0})
+		_, _ = rwc.Write([]byte{0x80, 0x82, 0, 0, 0, 0, 'l', 'o'})
+
+		typ, data, err := server.ReadMessage()
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(typ).To(Equal(WebSocketTextMessage))
+		NewWithT(t).Expect(string(data)).To(Equal("hello"))
+
+		pong := make([]byte, 2)
+		_, err = io.ReadFull(rwc, pong)
+		NewWithT(t).Expect(err).To(BeNil())
+		NewWithT(t).Expect(pong).To(Equal([]byte{0x8a, 0x00}))
+	})
+
+	t.Run("unmasked frame from client", func(t *testing.T) {
+		server, client := newWebSocketConnPair(t)
+
+		_, _ = client.rwc.Write([]byte{0x81, 0x01, 'x'})
+
+		_, _, err := server.ReadMessage()
+		NewWithT(t).Expect(err).To(Equal(&WebSocketCloseError{Code: WebSocketCloseProtocolError, Reason: "frame from client should be masked"}))
+
+		_, _, err = client.ReadMessage()
+		NewWithT(t).Expect(err.(*WebSocketCloseError).Code).To(Equal(WebSocketCloseProtocolError))
+	})
+
+	t.Run("message too big", func(t *testing.T) {
+		server, client := newWebSocketConnPair(t)
+		server.SetMaxMessageSize(4)
+
+		go func() {
+			_ = client.WriteMessage(WebSocketTextMessage, []byte("hello"))
+		}()
+
+		_, _, err := server.ReadMessage()
+		NewWithT(t).Expect(err.(*WebSocketCloseError).Code).To(Equal(WebSocketCloseMessageTooBig))
+
+		_, _, err = client.ReadMessage()
+		NewWithT(t).Expect(err.(*WebSocketCloseError).Code).To(Equal(WebSocketCloseMessageTooBig))
+	})
+
+	t.Run("frame too big without max message size", func(t *testing.T) {
+		server, client := newWebSocketConnPair(t)
+		server.SetMaxMessageSize(0)
+
+		// masked binary frame declaring 1<<62 bytes, without payload
+		_, _ = client.rwc.Write([]byte{0x82, 0x80 | 127, 0x40, 0, 0, 0, 0, 0, 0, 0, 1, 2, 3, 4})
+
+		_, _, err := server.ReadMessage()
+		NewWithT(t).Expect(err.(*WebSocketCloseError).Code).To(Equal(WebSocketCloseMessageTooBig))
+	})
+
+	t.Run("continuation frame too big", func(t *testing.T) {
+		server, client := newWebSocketConnPair(t)
+		server.SetMaxMessageSize(4)
+
+		// masked binary frame of 1 byte, then continuation declaring 1<<63-1 bytes
+		_, _ = client.rwc.Write([]byte{0x02, 0x80 | 1, 1, 2, 3, 4, 'x'})
+		_, _ = client.rwc.Write([]byte{0x80, 0x80 | 127, 0x7f, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 1, 2, 3, 4})
+
+		_, _, err := server.ReadMessage()
+		NewWithT(t).Expect(err.(*WebSocketCloseError).Code).To(Equal(WebSocketCloseMessageTooBig))
+	})
+
+	t.Run("invalid utf8", func(t *testing.T) {
+		server, client := newWebSocketConnPair(t)
+
+		go func() {
+			_ = client.WriteMessage(WebSocketTextMessage, []byte{0xff, 0xfe})
+		}()
+
+		_, _, err := server.ReadMessage()
+		NewWithT(t).Expect(err.(*WebSocketCloseError).Code).To(Equal(WebSocketCloseInvalidPayload))
+	})
+
+	t.Run("close", func(t *testing.T) {
+		server, client := newWebSocketConnPair(t)
+
+		go func() {
+			_ = client.writeClose(WebSocketCloseGoingAway, "bye")
+		}()
+
+		_, _, err := server.ReadMessage()
+		NewWithT(t).Expect(err).To(Equal(&WebSocketCloseError{Code: WebSocketCloseGoingAway, Reason: "bye"}))
+
+		// echoed
+		_, _, err = client.ReadMessage()
+		NewWithT(t).Expect(err).To(Equal(&WebSocketCloseError{Code: WebSocketCloseGoingAway}))
+
+		NewWithT(t).Expect(server.WriteMessage(WebSocketTextMessage, []byte("x"))).To(Equal(ErrWebSocketClosed))
+	})
+}
+
+func newWebSocketConnPair(t *testing.T) (server *WebSocketConn, client *WebSocketConn) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	accepted := make(chan net.Conn)
+
+	go func() {
+		c, _ := ln.Accept()
+		accepted <- c
+	}()
+
+	clientConn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serverConn := <-accepted
+
+	t.Cleanup(func() {
+		_ = clientConn.Close()
+		_ = serverConn.Close()
+	})
+
+	return NewWebSocketConn(serverConn, nil, true), NewWebSocketConn(clientConn, nil, false)
+}
@@ -0,0 +1,71 @@
+package testify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/go-courier/logr"
+)
+
+func NewMockLogger() *MockLogger {
+	return &MockLogger{store: &mockLogStore{}}
+}
+
+// MockLogger records entries of logs with values, shared by loggers derived by WithValues
+type MockLogger struct {
+	values []interface{}
+	store  *mockLogStore
+}
+
+type mockLogStore struct {
+	mu      sync.Mutex
+	entries []LogEntry
+}
+
+type LogEntry struct {
+	Level  logr.Level
+	Msg    string
+	Fields map[string]interface{}
+}
+
+var _ logr.Logger = (*MockLogger)(nil)
+
+func (l *MockLogger) Entries() []LogEntry {
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+	return append([]LogEntry{}, l.store.entries...)
+}
+
+func (l *MockLogger) record(level logr.Level, msg string) {
+	fields := map[string]interface{}{}
+	for i := 0; i+1 < len(l.values); i += 2 {
+		fields[fmt.Sprint(l.values[i])] = l.values[i+1]
+	}
+
+	l.store.mu.Lock()
+	defer l.store.mu.Unlock()
+	l.store.entries = append(l.store.entries, LogEntry{Level: level, Msg: msg, Fields: fields})
+}
+
+func (l *MockLogger) Start(ctx context.Context, name string, keyAndValues ...any) (context.Context, logr.Logger) {
+	return ctx, l
+}
+
+func (l *MockLogger) End() {}
+
+func (l *MockLogger) WithValues(keyAndValues ...any) logr.Logger {
+	return &MockLogger{
+		values: append(append([]interface{}{}, l.values...), keyAndValues...),
+		store:  l.store,
+	}
+}
+
+func (l *MockLogger) Debug(msg string, args ...any) {
+	l.record(logr.DebugLevel, fmt.Sprintf(msg, args...))
+}
+func (l *MockLogger) Info(msg string, args ...any) {
+	l.record(logr.InfoLevel, fmt.Sprintf(msg, args...))
+}
+func (l *MockLogger) Warn(err error)  { l.record(logr.WarnLevel, err.Error()) }
+func (l *MockLogger) Error(err error) { l.record(logr.ErrorLevel, err.Error()) }
@@ -6,6 +6,7 @@ import (
 	"net/textproto"
 	"net/url"
 	"reflect"
+	"sort"
 	"sync"
 
 	contextx "github.com/go-courier/x/context"
@@ -88,6 +89,56 @@ func (op TransformerOption) String() string {
 	return values.Encode()
 }
 
+func defaultMIME(typ typesx.Type) string {
+	if _, ok := typesx.EncodingTextMarshalerTypeReplacer(typ); ok {
+		return "plain"
+	}
+
+	indirectType := typesx.Deref(typ)
+
+	switch indirectType.Kind() {
+	case reflect.Slice:
+		if indirectType.Elem().PkgPath() == "" && indirectType.Elem().Kind() == reflect.Uint8 {
+			// bytes
+			return "plain"
+		}
+		return "json"
+	case reflect.Struct:
+		// *mime/multipart.FileHeader
+		if indirectType.PkgPath() == "mime/multipart" && indirectType.Name() == "FileHeader" {
+			return "octet-stream"
+		}
+		return "json"
+	case reflect.Map, reflect.Array:
+		return "json"
+	default:
+		return "plain"
+	}
+}
+
+// NegotiableTransformer could be offered as alternative of default media type in content negotiation of response,
+// like application/xml for struct values which encoded as application/json by default
+type NegotiableTransformer interface {
+	Transformer
+	Negotiable(typ typesx.Type) bool
+}
+
+// ProducibleMIMEs returns media types which values of typ could be encoded to by TransformerMgrDefault, default first
+func ProducibleMIMEs(typ typesx.Type) []string {
+	return ProducibleMIMEsOf(TransformerMgrDefault, typ)
+}
+
+// ProducibleMIMEsOf returns media types which values of typ could be encoded to by mgr, default first,
+// nil when mgr could not list them
+func ProducibleMIMEsOf(mgr TransformerMgr, typ typesx.Type) []string {
+	if p, ok := mgr.(interface {
+		ProducibleMIMEs(typ typesx.Type) []string
+	}); ok {
+		return p.ProducibleMIMEs(typ)
+	}
+	return nil
+}
+
 var TransformerMgrDefault = &TransformerFactory{}
 
 type TransformerFactory struct {
@@ -107,6 +158,33 @@ func (c *TransformerFactory) Register(transformers ...Transformer) {
 	}
 }
 
+// ProducibleMIMEs returns media type of default transformer of typ,
+// and media types of registered NegotiableTransformer for typ in order of media type.
+func (c *TransformerFactory) ProducibleMIMEs(typ typesx.Type) []string {
+	defaultTransformer, ok := c.transformerSet[defaultMIME(typ)]
+	if !ok {
+		return nil
+	}
+
+	mimes := []string{defaultTransformer.Names()[0]}
+
+	alternatives := make([]string, 0)
+
+	for name, transformer := range c.transformerSet {
+		// registered by each of names
+		if name != transformer.Names()[0] || name == mimes[0] {
+			continue
+		}
+		if negotiable, ok := transformer.(NegotiableTransformer); ok && negotiable.Negotiable(typ) {
+			alternatives = append(alternatives, name)
+		}
+	}
+
+	sort.Strings(alternatives)
+
+	return append(mimes, alternatives...)
+}
+
 func (c *TransformerFactory) NewTransformer(ctx context.Context, typ typesx.Type, opt TransformerOption) (Transformer, error) {
 	if ctx == nil {
 		ctx = context.Background()
@@ -119,32 +197,7 @@ func (c *TransformerFactory) NewTransformer(ctx context.Context, typ typesx.Type
 	}
 
 	if opt.MIME == "" {
-		indirectType := typesx.Deref(typ)
-
-		switch indirectType.Kind() {
-		case reflect.Slice:
-			if indirectType.Elem().PkgPath() == "" && indirectType.Elem().Kind() == reflect.Uint8 {
-				// bytes
-				opt.MIME = "plain"
-			} else {
-				opt.MIME = "json"
-			}
-		case reflect.Struct:
-			// *mime/multipart.FileHeader
-			if indirectType.PkgPath() == "mime/multipart" && indirectType.Name() == "FileHeader" {
-				opt.MIME = "octet-stream"
-			} else {
-				opt.MIME = "json"
-			}
-		case reflect.Map, reflect.Array:
-			opt.MIME = "json"
-		default:
-			opt.MIME = "plain"
-		}
-
-		if _, ok := typesx.EncodingTextMarshalerTypeReplacer(typ); ok {
-			opt.MIME = "plain"
-		}
+		opt.MIME = defaultMIME(typ)
 	}
 
 	if ct, ok := c.transformerSet[opt.MIME]; ok {
@@ -14,6 +14,7 @@ import (
 
 	"github.com/go-courier/httptransport/httpx"
 	verrors "github.com/go-courier/httptransport/validator"
+	contextx "github.com/go-courier/x/context"
 	typesutil "github.com/go-courier/x/types"
 	"github.com/pkg/errors"
 )
@@ -107,6 +108,127 @@ const (
 	defaultMaxMemory = 32 << 20 // 32 MB
 )
 
+// ErrMultipartLimitExceeded returned when multipart/form-data exceeds MultipartLimits
+var ErrMultipartLimitExceeded = errors.New("multipart limit exceeded")
+
+// MultipartLimits limits decoding of multipart/form-data, zero value means no limit.
+type MultipartLimits struct {
+	// max bytes of non-file parts stored in memory, remainder stored on disk in temporary files.
+	// default 32 MB
+	MaxMemory int64
+	// max count of files
+	MaxFiles int
+	// max bytes of each file
+	MaxFileSize int64
+}
+
+func (limits MultipartLimits) maxMemory() int64 {
+	if limits.MaxMemory > 0 {
+		return limits.MaxMemory
+	}
+	return defaultMaxMemory
+}
+
+// maxParts same as default of multipart.Reader.ReadForm
+const maxParts = 1000
+
+// readForm reads parts one by one like multipart.Reader.ReadForm,
+// but stops as soon as limits exceeded, instead of checking after all files stored.
+func (limits MultipartLimits) readForm(reader *multipart.Reader) (_ *multipart.Form, err error) {
+	form := &multipart.Form{
+		Value: map[string][]string{},
+		File:  map[string][]*multipart.FileHeader{},
+	}
+
+	defer func() {
+		if err != nil {
+			_ = form.RemoveAll()
+		}
+	}()
+
+	errMessageTooLarge := errors.Wrap(ErrMultipartLimitExceeded, multipart.ErrMessageTooLarge.Error())
+
+	maxMemory := limits.maxMemory()
+	files := 0
+
+	for parts := 0; ; parts++ {
+		if parts >= maxParts {
+			return nil, errMessageTooLarge
+		}
+
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		name := part.FormName()
+		if name == "" {
+			continue
+		}
+
+		filename := part.FileName()
+
+		if filename == "" {
+			b := bytes.NewBuffer(nil)
+			n, err := io.CopyN(b, part, maxMemory+1)
+			if err != nil && err != io.EOF {
+				return nil, err
+			}
+			if maxMemory -= n; maxMemory < 0 {
+				return nil, errMessageTooLarge
+			}
+			form.Value[name] = append(form.Value[name], b.String())
+			continue
+		}
+
+		if files++; limits.MaxFiles > 0 && files > limits.MaxFiles {
+			return nil, errors.Wrapf(ErrMultipartLimitExceeded, "files should not be more than %d", limits.MaxFiles)
+		}
+
+		var content io.Reader = part
+		if limits.MaxFileSize > 0 {
+			// one more byte for checking exceeded
+			content = io.LimitReader(part, limits.MaxFileSize+1)
+		}
+
+		fh, err := newFileHeader(func(w *multipart.Writer) (io.Writer, error) {
+			return w.CreatePart(part.Header)
+		}, name, content, maxMemory)
+		if err != nil {
+			return nil, err
+		}
+
+		form.File[name] = append(form.File[name], fh)
+
+		if limits.MaxFileSize > 0 && fh.Size > limits.MaxFileSize {
+			return nil, errors.Wrapf(ErrMultipartLimitExceeded, "file %s of %s should not be larger than %d bytes", filename, name, limits.MaxFileSize)
+		}
+
+		if maxMemory -= fh.Size; maxMemory < 0 {
+			// rest of files stored on disk
+			maxMemory = 0
+		}
+	}
+
+	return form, nil
+}
+
+type contextKeyMultipartLimits struct{}
+
+func ContextWithMultipartLimits(ctx context.Context, limits MultipartLimits) context.Context {
+	return contextx.WithValue(ctx, contextKeyMultipartLimits{}, limits)
+}
+
+func MultipartLimitsFromContext(ctx context.Context) MultipartLimits {
+	if limits, ok := ctx.Value(contextKeyMultipartLimits{}).(MultipartLimits); ok {
+		return limits
+	}
+	return MultipartLimits{}
+}
+
 func (transformer *TransformerMultipart) DecodeFrom(ctx context.Context, r io.Reader, v interface{}, headers ...textproto.MIMEHeader) error {
 	rv, ok := v.(reflect.Value)
 	if !ok {
@@ -119,8 +241,9 @@ func (transformer *TransformerMultipart) DecodeFrom(ctx context.Context, r io.Re
 		return err
 	}
 
-	reader := multipart.NewReader(r, params["boundary"])
-	form, err := reader.ReadForm(defaultMaxMemory)
+	limits := MultipartLimitsFromContext(ctx)
+
+	form, err := limits.readForm(multipart.NewReader(r, params["boundary"]))
 	if err != nil {
 		return err
 	}
@@ -163,25 +286,43 @@ func MustNewFileHeader(fieldName string, filename string, r io.Reader) *multipar
 }
 
 func NewFileHeader(fieldName string, filename string, r io.Reader) (*multipart.FileHeader, error) {
-	buffer := bytes.NewBuffer(nil)
-	multipartWriter := multipart.NewWriter(buffer)
+	return newFileHeader(func(w *multipart.Writer) (io.Writer, error) {
+		return w.CreateFormFile(fieldName, filename)
+	}, fieldName, r, defaultMaxMemory)
+}
 
-	filePart, err := multipartWriter.CreateFormFile(fieldName, filename)
+// newFileHeader streams r as single part through multipart.Reader.ReadForm,
+// which is the only way to create FileHeader with content,
+// and content larger than maxMemory stored on disk.
+func newFileHeader(createPart func(w *multipart.Writer) (io.Writer, error), fieldName string, r io.Reader, maxMemory int64) (*multipart.FileHeader, error) {
+	pr, pw := io.Pipe()
+	multipartWriter := multipart.NewWriter(pw)
+
+	go func() {
+		part, err := createPart(multipartWriter)
+		if err == nil {
+			_, err = io.Copy(part, r)
+		}
+		if err == nil {
+			err = multipartWriter.Close()
+		}
+		_ = pw.CloseWithError(err)
+	}()
+
+	form, err := multipart.NewReader(pr, multipartWriter.Boundary()).ReadForm(maxMemory)
+	// unblocks writing when reading failed
+	_ = pr.CloseWithError(io.ErrClosedPipe)
 	if err != nil {
 		return nil, err
 	}
-	if _, err := io.Copy(filePart, r); err != nil {
-		return nil, err
-	}
-	multipartWriter.Close()
 
-	reader := multipart.NewReader(buffer, multipartWriter.Boundary())
-	form, err := reader.ReadForm(int64(buffer.Len()))
-	if err != nil {
-		return nil, err
+	files := form.File[fieldName]
+	if len(files) == 0 {
+		_ = form.RemoveAll()
+		return nil, errors.Errorf("missing file of %s", fieldName)
 	}
 
-	return form.File[fieldName][0], nil
+	return files[0], nil
 }
 
 func NewFormPartWriter(createPartWriter func(header textproto.MIMEHeader) (io.Writer, error)) *FormPartWriter {
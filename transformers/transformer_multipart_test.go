@@ -15,6 +15,7 @@ import (
 	"github.com/go-courier/x/ptr"
 	typesutil "github.com/go-courier/x/types"
 	. "github.com/onsi/gomega"
+	"github.com/pkg/errors"
 )
 
 func TestMultipartTransformer(t *testing.T) {
@@ -179,6 +180,87 @@ text
 		NewWithT(t).Expect(err).To(BeNil())
 		NewWithT(t).Expect(testData).To(Equal(data))
 	})
+
+	t.Run("DecodeAndValidate with limits", func(t *testing.T) {
+		header := textproto.MIMEHeader{
+			"Content-type": []string{
+				mime.FormatMediaType(ct.Names()[0], map[string]string{
+					"boundary": boundary,
+				}),
+			},
+		}
+
+		cases := map[string]MultipartLimits{
+			"max files":     {MaxFiles: 2},
+			"max file size": {MaxFileSize: 4},
+		}
+
+		for name, limits := range cases {
+			t.Run(name, func(t *testing.T) {
+				testData := TestData{}
+
+				err := ct.DecodeFrom(ContextWithMultipartLimits(context.Background(), limits), bytes.NewBufferString(parts), &testData, header)
+				NewWithT(t).Expect(errors.Is(err, ErrMultipartLimitExceeded)).To(BeTrue())
+			})
+		}
+
+		t.Run("stop reading once exceeded", func(t *testing.T) {
+			filePart := func(filename string, content string) string {
+				return "--" + boundary + `
+Content-Disposition: form-data; name="Files"; filename="` + filename + `"
+Content-Type: application/octet-stream
+
+` + content + "\n"
+			}
+
+			cases := map[string]MultipartLimits{
+				"max files":     {MaxFiles: 2},
+				"max file size": {MaxFileSize: 4},
+			}
+
+			for name, limits := range cases {
+				t.Run(name, func(t *testing.T) {
+					// endless content of third file
+					r := &countingReader{Reader: io.MultiReader(
+						bytes.NewBufferString(filePart("file0.txt", "text")+filePart("file1.txt", "text")+filePart("file2.txt", "")),
+						endlessReader{},
+					)}
+
+					err := ct.DecodeFrom(ContextWithMultipartLimits(context.Background(), limits), r, &TestData{}, header)
+					NewWithT(t).Expect(errors.Is(err, ErrMultipartLimitExceeded)).To(BeTrue())
+					NewWithT(t).Expect(r.n < 64<<10).To(BeTrue())
+				})
+			}
+		})
+
+		t.Run("in limits", func(t *testing.T) {
+			testData := TestData{}
+
+			err := ct.DecodeFrom(ContextWithMultipartLimits(context.Background(), MultipartLimits{MaxFiles: 3, MaxFileSize: 5}), bytes.NewBufferString(parts), &testData, header)
+			NewWithT(t).Expect(err).To(BeNil())
+			NewWithT(t).Expect(testData).To(Equal(data))
+		})
+	})
+}
+
+type endlessReader struct{}
+
+func (endlessReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 'a'
+	}
+	return len(p), nil
+}
+
+type countingReader struct {
+	io.Reader
+	n int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.n += n
+	return n, err
 }
 
 var boundary = "99bb5d156e61cf661d01fc370479b62a3451759d25d14711fd7e9db170f6"
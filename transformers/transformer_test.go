@@ -1,7 +1,11 @@
 package transformers
 
 import (
+	"reflect"
 	"testing"
+
+	typesutil "github.com/go-courier/x/types"
+	. "github.com/onsi/gomega"
 )
 
 func TestTransformerCache(t *testing.T) {
@@ -9,3 +13,40 @@ func TestTransformerCache(t *testing.T) {
 		t.Log(name, tf)
 	}
 }
+
+type YAMLTransformer struct {
+	TransformerJSON
+}
+
+func (YAMLTransformer) Names() []string {
+	return []string{"application/yaml", "yaml"}
+}
+
+func (YAMLTransformer) Negotiable(typ typesutil.Type) bool {
+	return true
+}
+
+func TestProducibleMIMEs(t *testing.T) {
+	structType := typesutil.FromRType(reflect.TypeOf(struct{ Name string }{}))
+	stringType := typesutil.FromRType(reflect.TypeOf(""))
+
+	t.Run("default", func(t *testing.T) {
+		NewWithT(t).Expect(ProducibleMIMEs(structType)).To(Equal([]string{"application/json", "application/xml"}))
+		NewWithT(t).Expect(ProducibleMIMEs(stringType)).To(Equal([]string{"text/plain"}))
+	})
+
+	t.Run("registered only", func(t *testing.T) {
+		mgr := &TransformerFactory{}
+		mgr.Register(&TransformerJSON{}, &TransformerURLEncoded{})
+
+		NewWithT(t).Expect(ProducibleMIMEsOf(mgr, structType)).To(Equal([]string{"application/json"}))
+		NewWithT(t).Expect(ProducibleMIMEsOf(mgr, stringType)).To(BeNil())
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		mgr := &TransformerFactory{}
+		mgr.Register(&TransformerJSON{}, &XMLTransformer{}, &YAMLTransformer{})
+
+		NewWithT(t).Expect(ProducibleMIMEsOf(mgr, structType)).To(Equal([]string{"application/json", "application/xml", "application/yaml"}))
+	})
+}
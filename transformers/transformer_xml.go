@@ -30,6 +30,11 @@ func (XMLTransformer) NamedByTag() string {
 	return "xml"
 }
 
+// Negotiable offered as alternative of application/json for struct values
+func (XMLTransformer) Negotiable(typ typesutil.Type) bool {
+	return typesutil.Deref(typ).Kind() == reflect.Struct
+}
+
 func (XMLTransformer) New(context.Context, typesutil.Type) (Transformer, error) {
 	return &XMLTransformer{}, nil
 }